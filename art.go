@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"log"
+	"math"
+	"os"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+type artTool int
+
+const (
+	artDraw artTool = iota
+	artErase
+	artStamp
+)
+
+var artTools = []string{"Draw", "Erase", "Stamp"}
+
+const (
+	maxThickness = 50
+	// stampsDir holds the stamp images, named by their ID like 3.png.
+	stampsDir = "assets/stamps"
+)
+
+var (
+	// pixel is stretched to draw lines.
+	pixel *ebiten.Image
+	// stampImgs caches the stamp images, or nil for missing ones.
+	stampImgs = make(map[int]*ebiten.Image)
+)
+
+func init() {
+	pixel, _ = ebiten.NewImage(1, 1, ebiten.FilterDefault)
+	pixel.Fill(color.White)
+}
+
+// stampImage returns the image of the stamp id, or nil if there is none.
+func stampImage(id int) *ebiten.Image {
+	if img, ok := stampImgs[id]; ok {
+		return img
+	}
+	var img *ebiten.Image
+	f, err := os.Open(fmt.Sprintf("%s/%d.png", stampsDir, id))
+	if err == nil {
+		defer f.Close()
+		if src, _, err := image.Decode(f); err != nil {
+			log.Println(err)
+		} else {
+			img, _ = ebiten.NewImageFromImage(src, ebiten.FilterDefault)
+		}
+	}
+	stampImgs[id] = img
+	return img
+}
+
+// stampRect is the area a stamp covers in layer pixels.
+func stampRect(s level.Stamp) image.Rectangle {
+	w, h := tileSize, tileSize
+	if img := stampImage(s.ID); img != nil {
+		w, h = img.Size()
+	}
+	return image.Rect(s.X, s.Y, s.X+w, s.Y+h)
+}
+
+func (e *Editor) artTab(l layer) {
+	e.layer = l
+	if e.locked(l) {
+		imgui.Text("This layer is locked.")
+	}
+	for i, name := range artTools {
+		if i > 0 {
+			imgui.SameLine()
+		}
+		if imgui.RadioButton(name, e.artTool == artTool(i)) {
+			e.artTool = artTool(i)
+		}
+	}
+	switch e.artTool {
+	case artStamp:
+		if imgui.InputInt("Stamp", &e.stampID) && e.stampID < 0 {
+			e.stampID = 0
+		}
+		imgui.Text("Left click to place, right click to remove.")
+	case artDraw:
+		imgui.ColorEdit3V("Color", &e.artColor, imgui.ColorEditFlagsHEX)
+		fallthrough
+	default:
+		imgui.SliderInt("Thickness", &e.artThickness, 1, maxThickness)
+	}
+}
+
+// layerCam is the camera of a layer that scrolls at parallax times the
+// speed of the blocks.
+func (e *Editor) layerCam(screen *ebiten.Image, parallax float64) ebiten.GeoM {
+	bounds := screen.Bounds()
+	var centerX, centerY = float64(bounds.Dx()) / 2, float64(bounds.Dy()) / 2
+
+	cam := ebiten.GeoM{}
+	cam.Translate(e.cam.Element(0, 2)*parallax, e.cam.Element(1, 2)*parallax)
+	cam.Translate(centerX, centerY)               // center on screen
+	scaleAround(&cam, -centerX, -centerY, e.zoom) // zoom around center
+	return cam
+}
+
+// cursorArt returns the mouse cursor in the pixels of an art layer.
+func (e *Editor) cursorArt(screen *ebiten.Image, al level.ArtLayer) (image.Point, bool) {
+	g := e.layerCam(screen, level.Parallax[al])
+	if !g.IsInvertible() {
+		return image.Point{}, false
+	}
+	g.Invert()
+	mx, my := ebiten.CursorPosition()
+	x, y := g.Apply(float64(mx), float64(my))
+	return image.Pt(int(math.Floor(x)), int(math.Floor(y))), true
+}
+
+func (e *Editor) updateArt(screen *ebiten.Image, al level.ArtLayer) {
+	p, ok := e.cursorArt(screen, al)
+	if !ok {
+		return
+	}
+	art := &e.art[al]
+	switch e.artTool {
+	case artStamp:
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			after := art.Clone()
+			after.Stamps = append(after.Stamps, level.Stamp{X: p.X, Y: p.Y, ID: int(e.stampID)})
+			e.do(&artEdit{art: art, before: art.Clone(), after: after})
+		} else if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+			// remove the stamp on top
+			for i := len(art.Stamps) - 1; i >= 0; i-- {
+				if p.In(stampRect(art.Stamps[i])) {
+					after := art.Clone()
+					after.Stamps = append(after.Stamps[:i], after.Stamps[i+1:]...)
+					e.do(&artEdit{art: art, before: art.Clone(), after: after})
+					break
+				}
+			}
+		}
+	default:
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			return
+		}
+		if e.line == nil {
+			e.line = &level.Line{
+				Color:     f3tocol(e.artColor),
+				Thickness: int(e.artThickness),
+				Erase:     e.artTool == artErase,
+			}
+			e.lineLayer = al
+		}
+		if n := len(e.line.Points); n == 0 || e.line.Points[n-1] != p {
+			e.line.Points = append(e.line.Points, p)
+		}
+	}
+}
+
+// endLine records the line drawn since the mouse was pressed.
+func (e *Editor) endLine() {
+	if e.line == nil {
+		return
+	}
+	art := &e.art[e.lineLayer]
+	after := art.Clone()
+	after.Lines = append(after.Lines, *e.line)
+	e.line = nil
+	e.do(&artEdit{art: art, before: art.Clone(), after: after})
+}
+
+// drawArt draws the art layers, back to front.
+func (e *Editor) drawArt(screen *ebiten.Image, layers ...level.ArtLayer) {
+	w, h := screen.Size()
+	if e.artCanvas != nil {
+		if cw, ch := e.artCanvas.Size(); cw != w || ch != h {
+			e.artCanvas.Dispose()
+			e.artCanvas = nil
+		}
+	}
+	if e.artCanvas == nil {
+		e.artCanvas, _ = ebiten.NewImage(w, h, ebiten.FilterDefault)
+	}
+	for _, al := range layers {
+		alpha := e.alpha(artLayer(al))
+		if alpha == 0 {
+			continue
+		}
+		cam := e.layerCam(screen, level.Parallax[al])
+		art := &e.art[al]
+		for _, s := range art.Stamps {
+			drawStamp(screen, cam, s, alpha)
+		}
+		// lines go on a canvas of their own so erasing leaves the stamps
+		e.artCanvas.Clear()
+		for i := range art.Lines {
+			drawLine(e.artCanvas, cam, &art.Lines[i])
+		}
+		if e.line != nil && e.lineLayer == al {
+			drawLine(e.artCanvas, cam, e.line)
+		}
+		op := &ebiten.DrawImageOptions{}
+		op.ColorM.Scale(1, 1, 1, alpha)
+		screen.DrawImage(e.artCanvas, op)
+	}
+}
+
+func drawStamp(dst *ebiten.Image, cam ebiten.GeoM, s level.Stamp, alpha float64) {
+	if img := stampImage(s.ID); img != nil {
+		op := &ebiten.DrawImageOptions{}
+		op.ColorM.Scale(1, 1, 1, alpha)
+		op.GeoM.Translate(float64(s.X), float64(s.Y))
+		op.GeoM.Concat(cam)
+		dst.DrawImage(img, op)
+		return
+	}
+	// a placeholder for stamps without an image
+	r := stampRect(s)
+	x0, y0 := cam.Apply(float64(r.Min.X), float64(r.Min.Y))
+	x1, y1 := cam.Apply(float64(r.Max.X), float64(r.Max.Y))
+	ebitenutil.DrawRect(dst, x0, y0, x1-x0, y1-y0, color.NRGBA{0x80, 0x80, 0x80, uint8(0x80 * alpha)})
+	ebitenutil.DebugPrintAt(dst, fmt.Sprint(s.ID), int(x0), int(y0))
+}
+
+// drawLine draws l as a square at each point joined by rotated rects.
+func drawLine(dst *ebiten.Image, cam ebiten.GeoM, l *level.Line) {
+	op := &ebiten.DrawImageOptions{}
+	if l.Erase {
+		op.CompositeMode = ebiten.CompositeModeDestinationOut
+	} else {
+		op.ColorM.Scale(float64(l.Color.R)/0xff, float64(l.Color.G)/0xff, float64(l.Color.B)/0xff, 1)
+	}
+	t := float64(l.Thickness)
+	for i, p := range l.Points {
+		x, y := float64(p.X), float64(p.Y)
+		op.GeoM.Reset()
+		op.GeoM.Scale(t, t)
+		op.GeoM.Translate(x-t/2, y-t/2)
+		op.GeoM.Concat(cam)
+		dst.DrawImage(pixel, op)
+		if i == 0 {
+			continue
+		}
+		prev := l.Points[i-1]
+		dx, dy := x-float64(prev.X), y-float64(prev.Y)
+		op.GeoM.Reset()
+		op.GeoM.Scale(math.Hypot(dx, dy), t)
+		op.GeoM.Translate(0, -t/2)
+		op.GeoM.Rotate(math.Atan2(dy, dx))
+		op.GeoM.Translate(float64(prev.X), float64(prev.Y))
+		op.GeoM.Concat(cam)
+		dst.DrawImage(pixel, op)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+const autosaveInterval = 30 * time.Second
+
+// journal is the unsaved course as autosaved into the config dir.
+type journal struct {
+	// Path is the file the course was opened from, if any.
+	Path  string
+	Level string
+	Time  time.Time
+}
+
+var (
+	// journalMu keeps the background writes from racing each other.
+	journalMu sync.Mutex
+	// journalSeq numbers the writes and removals of the journal in the
+	// order they were asked for, and journalDone is the newest one carried
+	// out. One that runs after a newer one is dropped, so a late write
+	// can't bring back a journal that was removed.
+	journalSeq, journalDone int64
+)
+
+// nextJournal numbers a write or removal of the journal. It must be
+// called before starting the goroutine that does it.
+func nextJournal() int64 {
+	return atomic.AddInt64(&journalSeq, 1)
+}
+
+// journalTurn reports whether the write or removal seq is still the newest
+// one carried out, and records it. journalMu must be held.
+func journalTurn(seq int64) bool {
+	if seq < journalDone {
+		return false
+	}
+	journalDone = seq
+	return true
+}
+
+func journalPath() string {
+	return filepath.Join(configDir, "autosave.json")
+}
+
+func loadJournal() (*journal, error) {
+	b, err := ioutil.ReadFile(journalPath())
+	if err != nil {
+		return nil, err
+	}
+	var j journal
+	err = json.Unmarshal(b, &j)
+	return &j, err
+}
+
+// writeJournal writes j as the journal, unless it was overtaken.
+func writeJournal(seq int64, j *journal) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if !journalTurn(seq) {
+		return nil
+	}
+	return writeFileAtomic(journalPath(), b, 0600)
+}
+
+// removeJournal removes the journal, unless it was overtaken.
+func removeJournal(seq int64) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if !journalTurn(seq) {
+		return
+	}
+	if err := os.Remove(journalPath()); err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+}
+
+// updateAutosave journals the course every autosaveInterval while it has
+// unsaved changes, and drops the journal once it is saved.
+func (e *Editor) updateAutosave() {
+	if time.Since(e.autosavedAt) < autosaveInterval {
+		return
+	}
+	e.autosavedAt = time.Now()
+	e.autosave(false)
+}
+
+// autosave writes the journal in the background, or right away if wait is
+// set, like when the editor is closing.
+func (e *Editor) autosave(wait bool) {
+	if !e.dirty() {
+		if e.journaled {
+			e.journaled = false
+			seq := nextJournal()
+			if wait {
+				// the editor exits before a goroutine would get to run
+				removeJournal(seq)
+			} else {
+				go removeJournal(seq)
+			}
+		}
+		return
+	}
+	changes := e.history.changes
+	if e.journaled && changes == e.autosaved && e.stroke == nil {
+		// nothing new since the last autosave
+		return
+	}
+	e.autosaved = changes
+	e.journaled = true
+
+	j := &journal{
+		Path:  e.path,
+		Level: level.Encode(e.current(), e.user()),
+		Time:  time.Now(),
+	}
+	seq := nextJournal()
+	write := func() {
+		if err := writeJournal(seq, j); err != nil {
+			log.Println("autosave:", err)
+		}
+	}
+	if wait {
+		write()
+	} else {
+		go write()
+	}
+}
+
+const PopupRestore = "Restore##PopupRestore"
+
+func (e *Editor) restorePopup() {
+	if e.openRestore {
+		e.openRestore = false
+		imgui.OpenPopup(PopupRestore)
+	}
+	if imgui.BeginPopupModalV(PopupRestore, nil, imgui.WindowFlagsAlwaysAutoResize) {
+		name := e.restore.Path
+		if name == "" {
+			name = "an untitled level"
+		}
+		imgui.Text(fmt.Sprintf("Unsaved changes to %s were recovered from %s.", name, e.restore.Time.Format(time.Stamp)))
+		imgui.Text("Do you want to restore them?")
+		if imgui.Button("Restore") {
+			if l, err := level.Parse(e.restore.Level); err != nil {
+				log.Println(err)
+			} else {
+				e.loadCourse(l)
+				e.path = e.restore.Path
+				e.history.unsaved = true
+			}
+			e.restore = nil
+			imgui.CloseCurrentPopup()
+		}
+		imgui.SameLine()
+		if imgui.Button("Discard") {
+			e.restore = nil
+			go removeJournal(nextJournal())
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestJournalOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "levedit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { configDir = old }(configDir)
+	configDir = dir
+
+	// the course was saved while an autosave was still waiting to run
+	write, remove := nextJournal(), nextJournal()
+	removeJournal(remove)
+	if err := writeJournal(write, &journal{Level: "stale"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadJournal(); !os.IsNotExist(err) {
+		t.Errorf("a late write brought back the journal: %v", err)
+	}
+
+	// written out of order, the newer write still wins
+	first, second := nextJournal(), nextJournal()
+	for _, w := range []struct {
+		seq   int64
+		level string
+	}{{second, "new"}, {first, "old"}} {
+		if err := writeJournal(w.seq, &journal{Level: w.level}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j, err := loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Level != "new" {
+		t.Errorf("journal = %q, want the newer write", j.Level)
+	}
+}
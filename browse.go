@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/fourst4r/levedit/pr2hub"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+const PopupBrowse = "Browse levels##PopupBrowse"
+
+// browseSource is a way of finding levels on the hub: a search or a list.
+type browseSource struct {
+	name         string
+	search, list string
+}
+
+var browseSources = []browseSource{
+	{name: "Search titles", search: pr2hub.SearchTitle},
+	{name: "Search authors", search: pr2hub.SearchUser},
+	{name: "Newest", list: pr2hub.ListNewest},
+	{name: "Best rated", list: pr2hub.ListBest},
+	{name: "Best today", list: pr2hub.ListBestToday},
+	{name: "Campaign", list: pr2hub.ListCampaign},
+}
+
+var searchOrders = []string{
+	pr2hub.OrderPopularity, pr2hub.OrderRating, pr2hub.OrderDate, pr2hub.OrderAlphabetical,
+}
+
+// levelSort orders a page of levels in the editor.
+type levelSort int
+
+const (
+	sortHub levelSort = iota
+	sortRating
+	sortPlays
+	sortTime
+)
+
+var levelSorts = []string{"Hub order", "Rating", "Plays", "Date"}
+
+// sortLevels returns a copy of levels ordered by, best, most played or
+// newest first.
+func sortLevels(levels []pr2hub.LevelInfo, by levelSort) []pr2hub.LevelInfo {
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	levels = append([]pr2hub.LevelInfo(nil), levels...)
+	sort.SliceStable(levels, func(i, j int) bool {
+		a, b := levels[i], levels[j]
+		switch by {
+		case sortRating:
+			return a.Rating > b.Rating
+		case sortPlays:
+			return atoi(a.PlayCount) > atoi(b.PlayCount)
+		case sortTime:
+			return atoi(a.Time) > atoi(b.Time)
+		}
+		return false
+	})
+	return levels
+}
+
+// browser is the state of the browse dialog.
+type browser struct {
+	source, order int32
+	query         string
+	page          int
+	sort          levelSort
+	req           *pr2hub.LevelsGetReq
+	// hubLevels is the page as the hub listed it, levels the same page in
+	// the chosen order
+	hubLevels, levels []pr2hub.LevelInfo
+	status            string
+	// selected is the LevelID of the selected level
+	selected string
+	// open downloads the selected level, read-only or as a copy
+	open     *pr2hub.LevelReq
+	openInfo pr2hub.LevelInfo
+	readOnly bool
+}
+
+// setLevels shows a page of levels from the hub, with the first selected.
+func (b *browser) setLevels(levels []pr2hub.LevelInfo) {
+	b.hubLevels = levels
+	b.levels = sortLevels(levels, b.sort)
+	b.selected = ""
+	if len(b.levels) > 0 {
+		b.selected = b.levels[0].LevelID
+	}
+}
+
+// setSort orders the page by, keeping the selected level.
+func (b *browser) setSort(by levelSort) {
+	b.sort = by
+	b.levels = sortLevels(b.hubLevels, by)
+}
+
+// selectedLevel returns the selected level, if it is on the page.
+func (b *browser) selectedLevel() (pr2hub.LevelInfo, bool) {
+	for _, l := range b.levels {
+		if l.LevelID == b.selected {
+			return l, true
+		}
+	}
+	return pr2hub.LevelInfo{}, false
+}
+
+// fetchLevels asks the hub for the current page of the source.
+func (e *Editor) fetchLevels() {
+	b := &e.browser
+	if b.req != nil {
+		b.req.Cancel()
+	}
+	if b.page < 1 {
+		b.page = 1
+	}
+	src := browseSources[b.source]
+	if src.list != "" {
+		b.req = e.hub.LevelList(context.Background(), src.list, b.page)
+	} else {
+		b.req = e.hub.SearchLevels(context.Background(), src.search, b.query, searchOrders[b.order], b.page)
+	}
+	b.setLevels(nil)
+	b.status = ""
+}
+
+// openBrowser shows the browse dialog, with the newest levels at first.
+func (e *Editor) openBrowser() {
+	if e.browser.page == 0 {
+		e.browser.source = 2 // Newest
+		e.fetchLevels()
+	}
+	imgui.OpenPopup(PopupBrowse)
+}
+
+func (e *Editor) browsePopup() {
+	b := &e.browser
+	imgui.SetNextWindowSize(imgui.Vec2{X: 640, Y: 0})
+	if !imgui.BeginPopupModalV(PopupBrowse, nil, imgui.WindowFlagsNone) {
+		return
+	}
+	if b.req != nil && b.req.Done() {
+		if err := b.req.Err(); err != nil {
+			log.Println(err)
+			b.status = err.Error()
+		} else if b.req.Resp.Error != "" {
+			b.status = fmt.Sprintf("pr2hub: %s", b.req.Resp.Error)
+		} else {
+			b.setLevels(b.req.Resp.Levels)
+			if len(b.levels) == 0 {
+				b.status = "No levels found"
+			}
+		}
+		b.req = nil
+	}
+
+	if imgui.BeginCombo("Find", browseSources[b.source].name) {
+		for i, src := range browseSources {
+			if imgui.Selectable(src.name) {
+				b.source, b.page = int32(i), 1
+				if src.list != "" {
+					e.fetchLevels()
+				}
+			}
+		}
+		imgui.EndCombo()
+	}
+	if browseSources[b.source].search != "" {
+		imgui.InputText("Search", &b.query)
+		if imgui.BeginCombo("Order", searchOrders[b.order]) {
+			for i, order := range searchOrders {
+				if imgui.Selectable(order) {
+					b.order = int32(i)
+				}
+			}
+			imgui.EndCombo()
+		}
+		if imgui.Button("Search") {
+			b.page = 1
+			e.fetchLevels()
+		}
+	}
+	// the hub only orders searches, see Order, so this sorts the levels
+	// already downloaded rather than all of them
+	if imgui.BeginCombo("Sort this page", levelSorts[b.sort]) {
+		for i, name := range levelSorts {
+			if imgui.Selectable(name) {
+				b.setSort(levelSort(i))
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.Separator()
+
+	imgui.BeginChildV("levels", imgui.Vec2{X: 0, Y: 300}, true, 0)
+	imgui.ColumnsV(5, "levels", false)
+	for _, head := range []string{"Title", "Author", "Rating", "Plays", "Date"} {
+		imgui.Text(head)
+		imgui.NextColumn()
+	}
+	imgui.Separator()
+	for _, l := range b.levels {
+		label := fmt.Sprintf("%s##%s", l.Title, l.LevelID)
+		if imgui.SelectableV(label, l.LevelID == b.selected, imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) {
+			b.selected = l.LevelID
+		}
+		imgui.NextColumn()
+		imgui.Text(l.Name)
+		imgui.NextColumn()
+		imgui.Text(fmt.Sprintf("%.2f", l.Rating))
+		imgui.NextColumn()
+		imgui.Text(l.PlayCount)
+		imgui.NextColumn()
+		imgui.Text(levelDate(l.Time))
+		imgui.NextColumn()
+	}
+	imgui.Columns()
+	if b.req != nil {
+		imgui.Text(fmt.Sprintf("Loading... %c", spinner()))
+	} else if b.status != "" {
+		imgui.Text(b.status)
+	}
+	imgui.EndChild()
+
+	if imgui.Button("< Prev") && b.page > 1 {
+		b.page--
+		e.fetchLevels()
+	}
+	imgui.SameLine()
+	imgui.Text(fmt.Sprintf("Page %d", b.page))
+	imgui.SameLine()
+	if imgui.Button("Next >") && len(b.levels) > 0 {
+		b.page++
+		e.fetchLevels()
+	}
+	l, ok := b.selectedLevel()
+	if ok {
+		imgui.PushTextWrapPos()
+		imgui.Text(l.Note)
+		imgui.PopTextWrapPos()
+	}
+	imgui.Separator()
+
+	if b.open != nil {
+		imgui.Text(fmt.Sprintf("Downloading the level... %c", spinner()))
+		if b.open.Done() {
+			if err := e.openBrowsed(); err != nil {
+				log.Println(err)
+				b.status = err.Error()
+			} else {
+				imgui.CloseCurrentPopup()
+			}
+		}
+	} else if ok {
+		if imgui.Button("Open read-only") {
+			b.open, b.openInfo, b.readOnly = e.hub.Level(context.Background(), l.LevelID, l.Version), l, true
+		}
+		imgui.SameLine()
+		if imgui.Button("Open as copy") {
+			b.open, b.openInfo, b.readOnly = e.hub.Level(context.Background(), l.LevelID, l.Version), l, false
+		}
+		imgui.SameLine()
+	}
+	if imgui.Button("Close") {
+		if b.open != nil {
+			b.open.Cancel()
+			b.open = nil
+		}
+		imgui.CloseCurrentPopup()
+	}
+	imgui.EndPopup()
+}
+
+// openBrowsed loads the level downloaded from the browse dialog.
+func (e *Editor) openBrowsed() error {
+	b := &e.browser
+	req := b.open
+	b.open = nil
+	if err := req.Err(); err != nil {
+		return err
+	}
+	l, err := level.Parse(req.Data)
+	if err != nil {
+		return err
+	}
+	e.loadCourse(l)
+	e.setOrigin(b.openInfo)
+	if b.readOnly {
+		e.readOnly = true
+	} else {
+		// a copy is only saved once the user saves it
+		e.history.unsaved = true
+	}
+	return nil
+}
+
+// levelDate formats the unix time the hub lists levels with.
+func levelDate(t string) string {
+	sec, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return t
+	}
+	return time.Unix(sec, 0).Format("2006-01-02")
+}
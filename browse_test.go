@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fourst4r/levedit/pr2hub"
+)
+
+func Test_sortLevels(t *testing.T) {
+	levels := []pr2hub.LevelInfo{
+		{Title: "a", Rating: 3.5, PlayCount: "10", Time: "300"},
+		{Title: "b", Rating: 4.5, PlayCount: "2", Time: "100"},
+		{Title: "c", Rating: 1, PlayCount: "999", Time: "200"},
+		{Title: "d", Rating: 4.5, PlayCount: "bad", Time: ""},
+	}
+	tests := []struct {
+		name string
+		by   levelSort
+		want []string
+	}{
+		{"hub", sortHub, []string{"a", "b", "c", "d"}},
+		{"rating", sortRating, []string{"b", "d", "a", "c"}},
+		{"plays", sortPlays, []string{"c", "a", "b", "d"}},
+		{"time", sortTime, []string{"a", "c", "b", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, info := range sortLevels(levels, tt.by) {
+				got = append(got, info.Title)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortLevels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got, want := titlesOf(levels), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sortLevels() changed the hub order to %v", got)
+	}
+}
+
+func titlesOf(levels []pr2hub.LevelInfo) []string {
+	var titles []string
+	for _, l := range levels {
+		titles = append(titles, l.Title)
+	}
+	return titles
+}
+
+func TestBrowserSort(t *testing.T) {
+	var b browser
+	b.setLevels([]pr2hub.LevelInfo{
+		{LevelID: "1", Title: "a", Rating: 1},
+		{LevelID: "2", Title: "b", Rating: 3},
+		{LevelID: "3", Title: "c", Rating: 2},
+	})
+	if l, ok := b.selectedLevel(); !ok || l.Title != "a" {
+		t.Fatalf("selected %v, want the first level", l)
+	}
+	b.selected = "3"
+
+	b.setSort(sortRating)
+	if got, want := titlesOf(b.levels), []string{"b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted by rating = %v, want %v", got, want)
+	}
+	if l, _ := b.selectedLevel(); l.Title != "c" {
+		t.Errorf("after sorting selected %q, want c", l.Title)
+	}
+	b.setSort(sortHub)
+	if got, want := titlesOf(b.levels), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("back in hub order = %v, want %v", got, want)
+	}
+	if l, _ := b.selectedLevel(); l.Title != "c" {
+		t.Errorf("back in hub order selected %q, want c", l.Title)
+	}
+
+	b.setLevels(nil)
+	if l, ok := b.selectedLevel(); ok {
+		t.Errorf("selected %v on an empty page", l)
+	}
+}
@@ -0,0 +1,46 @@
+// Command fakehub runs the in-memory pr2hub from pr2hubtest, so that the
+// editor can be used without network access:
+//
+//	fakehub -addr localhost:8080 -user test:test
+//	levedit -hub http://localhost:8080
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fourst4r/levedit/pr2hub/pr2hubtest"
+)
+
+type users []string
+
+func (u *users) String() string { return strings.Join(*u, ",") }
+
+func (u *users) Set(s string) error {
+	if !strings.Contains(s, ":") {
+		return fmt.Errorf("want name:pass, got %q", s)
+	}
+	*u = append(*u, s)
+	return nil
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	var accs users
+	flag.Var(&accs, "user", "account as name:pass, can be repeated (default test:test)")
+	flag.Parse()
+	if len(accs) == 0 {
+		accs = users{"test:test"}
+	}
+
+	hub := pr2hubtest.NewHub()
+	for _, acc := range accs {
+		i := strings.Index(acc, ":")
+		hub.AddUser(acc[:i], acc[i+1:])
+	}
+	log.Printf("Serving a fake pr2hub on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, hub))
+}
@@ -0,0 +1,383 @@
+// Command levedit-cli works on PR2 levels without opening a window, for
+// scripts and CI jobs on machines without a display.
+//
+// Usage:
+//
+//	levedit-cli <command> [flags] [args]
+//
+// Commands exit with 0 on success, 1 when the operation or a check fails
+// and 2 on bad usage. Pass -json to get machine readable output.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/fourst4r/levedit/pr2hub"
+)
+
+type command struct {
+	name, args, help string
+	run              func(fs *flag.FlagSet, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"validate", "<file>...", "check level files for problems", runValidate},
+		{"convert", "<in> <out>", "rewrite a level file, use - for stdin or stdout", runConvert},
+		{"info", "<file>...", "summarize level files", runInfo},
+		{"render", "<file> <out.png>", "draw a level to a PNG image, use - for stdout", runRender},
+		{"download", "<level id> [version]", "download a level from pr2hub", runDownload},
+		{"upload", "<file>", "upload a level to pr2hub", runUpload},
+	}
+}
+
+var (
+	// errFailed is returned by commands that already reported what failed.
+	errFailed   = errors.New("failed")
+	errBadFlags = errors.New("bad flags")
+)
+
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+var (
+	jsonOut bool
+	hubURL  string
+	// stdout and stderr are where commands write, which tests replace.
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.BoolVar(&jsonOut, "json", false, "print JSON")
+		fs.StringVar(&hubURL, "hub", pr2hub.DefaultBaseURL, "pr2hub server")
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "usage: levedit-cli %s [flags] %s\n", cmd.name, cmd.args)
+			fs.PrintDefaults()
+		}
+		err := cmd.run(fs, args[1:])
+		var uerr usageError
+		switch {
+		case err == nil:
+			return 0
+		case err == errBadFlags:
+			// the flag package already printed the usage
+			return 2
+		case errors.As(err, &uerr):
+			fmt.Fprintln(stderr, uerr)
+			fs.Usage()
+			return 2
+		case err != errFailed:
+			fmt.Fprintln(stderr, "levedit-cli:", err)
+		}
+		return 1
+	}
+	fmt.Fprintf(stderr, "levedit-cli: unknown command %q\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(stderr, "usage: levedit-cli <command> [flags] [args]")
+	fmt.Fprintln(stderr, "\ncommands:")
+	w := tabwriter.NewWriter(stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.help)
+	}
+	w.Flush()
+}
+
+// parse parses the flags and checks the number of positional args.
+func parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errBadFlags
+	}
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		return nil, usageError("wrong number of arguments")
+	}
+	return fs.Args(), nil
+}
+
+func hub() *pr2hub.Client {
+	c := pr2hub.NewClient(hubURL, nil)
+	c.Timeout = time.Minute
+	return c
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type validateResult struct {
+	File        string             `json:"file"`
+	OK          bool               `json:"ok"`
+	Error       string             `json:"error,omitempty"`
+	Diagnostics []level.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+func runValidate(fs *flag.FlagSet, args []string) error {
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	files, err := parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	results := make([]validateResult, len(files))
+	failed := false
+	for i, file := range files {
+		results[i] = validateResult{File: file, OK: true}
+		l, err := level.Load(file)
+		if err != nil {
+			results[i].OK = false
+			results[i].Error = err.Error()
+			failed = true
+			continue
+		}
+		diags := level.Validate(l)
+		results[i].Diagnostics = diags
+		if level.Errors(diags) > 0 || (*strict && len(diags) > 0) {
+			results[i].OK = false
+			failed = true
+		}
+	}
+	if jsonOut {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			switch {
+			case r.Error != "":
+				fmt.Fprintf(stdout, "%s: %s\n", r.File, r.Error)
+			case len(r.Diagnostics) == 0:
+				fmt.Fprintf(stdout, "%s: ok\n", r.File)
+			}
+			for _, d := range r.Diagnostics {
+				fmt.Fprintf(stdout, "%s: %s\n", r.File, d)
+			}
+		}
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+func runConvert(fs *flag.FlagSet, args []string) error {
+	user := fs.String("user", "", "author to sign the level as")
+	files, err := parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	l, err := level.Load(files[0])
+	if err != nil {
+		return err
+	}
+	return writeLevel(files[1], level.Encode(l, *user))
+}
+
+func writeLevel(path, data string) error {
+	if path == "-" {
+		_, err := fmt.Fprintln(stdout, data)
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(data), 0644)
+}
+
+func runInfo(fs *flag.FlagSet, args []string) error {
+	files, err := parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	infos := make(map[string]level.Info)
+	for _, file := range files {
+		l, err := level.Load(file)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		infos[file] = level.Describe(l.Course)
+	}
+	if jsonOut {
+		return printJSON(infos)
+	}
+	for _, file := range files {
+		printInfo(file, infos[file])
+	}
+	return nil
+}
+
+func printInfo(file string, info level.Info) {
+	w := tabwriter.NewWriter(stdout, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "%s\n", file)
+	fmt.Fprintf(w, "  title:\t%s\n", info.Title)
+	fmt.Fprintf(w, "  live:\t%t\n", info.Live)
+	fmt.Fprintf(w, "  background:\t#%s\n", info.Background)
+	fmt.Fprintf(w, "  blocks:\t%d in %d cells\n", info.Blocks, info.Cells)
+	fmt.Fprintf(w, "  bounds:\t%d,%d to %d,%d\n", info.MinX, info.MinY, info.MaxX, info.MaxY)
+	names := make([]string, 0, len(info.Counts))
+	for name := range info.Counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "    %s:\t%d\n", name, info.Counts[name])
+	}
+	w.Flush()
+}
+
+func runRender(fs *flag.FlagSet, args []string) error {
+	atlasPath := fs.String("atlas", level.DefaultAtlas, "block atlas image")
+	scale := fs.Float64("scale", 1, "size of the image relative to the level")
+	files, err := parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	if *scale <= 0 {
+		return usageError("scale must be positive")
+	}
+	atlas, err := level.LoadAtlas(*atlasPath)
+	if err != nil {
+		return err
+	}
+	l, err := level.Load(files[0])
+	if err != nil {
+		return err
+	}
+	c := l.Course
+	if files[1] == "-" {
+		return level.WritePNG(stdout, c, atlas, *scale)
+	}
+	f, err := os.Create(files[1])
+	if err != nil {
+		return err
+	}
+	if err := level.WritePNG(f, c, atlas, *scale); err != nil {
+		f.Close()
+		os.Remove(files[1])
+		return err
+	}
+	return f.Close()
+}
+
+// downloadResult is what download prints with -json. Data is the level
+// when it isn't written to a file.
+type downloadResult struct {
+	ID      string     `json:"id"`
+	Version string     `json:"version,omitempty"`
+	File    string     `json:"file,omitempty"`
+	Info    level.Info `json:"info"`
+	Data    string     `json:"data,omitempty"`
+}
+
+func runDownload(fs *flag.FlagSet, args []string) error {
+	out := fs.String("o", "-", "file to write the level to")
+	ids, err := parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	version := ""
+	if len(ids) == 2 {
+		version = ids[1]
+	}
+	req := hub().Level(context.Background(), ids[0], version)
+	if err := req.Wait(); err != nil {
+		return err
+	}
+	data := req.Data
+	l, err := level.Parse(data)
+	if err != nil {
+		return fmt.Errorf("level %s: %v", ids[0], err)
+	}
+	if !jsonOut {
+		return writeLevel(*out, data)
+	}
+	res := downloadResult{ID: ids[0], Version: version, Info: level.Describe(l.Course)}
+	// the hub says which version it sent, which matters for the newest
+	if val, err := url.ParseQuery(data); err == nil && val.Get("version") != "" {
+		res.Version = val.Get("version")
+	}
+	if *out == "-" {
+		res.Data = data
+	} else {
+		if err := writeLevel(*out, data); err != nil {
+			return err
+		}
+		res.File = *out
+	}
+	return printJSON(res)
+}
+
+func runUpload(fs *flag.FlagSet, args []string) error {
+	user := fs.String("user", os.Getenv("PR2HUB_USER"), "pr2hub user name, or $PR2HUB_USER")
+	token := fs.String("token", "", "login token, defaults to $PR2HUB_TOKEN")
+	overwrite := fs.Bool("overwrite", false, "overwrite a level with the same title")
+	files, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *token == "" {
+		*token = os.Getenv("PR2HUB_TOKEN")
+	}
+	if *user == "" || *token == "" {
+		return usageError("a user and token are required")
+	}
+	l, err := level.Load(files[0])
+	if err != nil {
+		return err
+	}
+	val := l.Values(*user)
+	val.Set("token", *token)
+	if *overwrite {
+		val.Set("overwrite_existing", "1")
+	} else {
+		val.Set("overwrite_existing", "0")
+	}
+	val.Set("override_banned", "0")
+	req := hub().UploadLevel(context.Background(), val.Encode())
+	if err := req.Wait(); err != nil {
+		return err
+	}
+	status, message := req.Resp.Get("status"), req.Resp.Get("message")
+	if jsonOut {
+		if err := printJSON(map[string]string{"status": status, "message": message}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(stdout, message)
+	}
+	switch status {
+	case "exists", "banned", "error":
+		return errFailed
+	}
+	return nil
+}
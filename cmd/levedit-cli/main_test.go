@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+	"github.com/fourst4r/levedit/pr2hub/pr2hubtest"
+)
+
+// runCLI runs the command line args and returns the exit code and what
+// was printed.
+func runCLI(t *testing.T, args ...string) (code int, out, errOut string) {
+	t.Helper()
+	var o, e bytes.Buffer
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	stdout, stderr = &o, &e
+	code = run(args)
+	return code, o.String(), e.String()
+}
+
+// writeLevels writes a valid level and one without a title into a temp
+// dir.
+func writeLevels(t *testing.T) (good, bad string, done func()) {
+	dir, err := ioutil.TempDir("", "levedit-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, title string) string {
+		l := &level.Level{Course: course.Default(), Settings: level.DefaultSettings()}
+		l.Title = title
+		for i, id := range []int{course.BlockPlayer1, course.BlockPlayer2, course.BlockPlayer3, course.BlockPlayer4, level.BlockFinish} {
+			l.Blocks.Push(i*level.TileSize, 0, id)
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(level.Encode(l, "alice")), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	return write("good.txt", "Good"), write("bad.txt", ""), func() { os.RemoveAll(dir) }
+}
+
+func Test_run(t *testing.T) {
+	good, bad, done := writeLevels(t)
+	defer done()
+	missing := filepath.Join(filepath.Dir(good), "missing.txt")
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no command", nil, 2},
+		{"unknown command", []string{"frobnicate"}, 2},
+		{"bad flag", []string{"validate", "-nope", good}, 2},
+		{"no files", []string{"validate"}, 2},
+		{"bad scale", []string{"render", "-scale", "0", good, "out.png"}, 2},
+		{"valid", []string{"validate", good}, 0},
+		{"invalid", []string{"validate", good, bad}, 1},
+		{"missing", []string{"validate", missing}, 1},
+		{"info", []string{"info", good, bad}, 0},
+		{"info missing", []string{"info", missing}, 1},
+		{"upload without token", []string{"upload", "-user", "", good}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, errOut := runCLI(t, tt.args...); code != tt.want {
+				t.Errorf("run(%q) = %d, want %d (stderr %q)", tt.args, code, tt.want, errOut)
+			}
+		})
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	good, bad, done := writeLevels(t)
+	defer done()
+	code, out, _ := runCLI(t, "validate", "-json", good, bad)
+	if code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	var results []validateResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if len(results) != 2 || results[0].File != good || !results[0].OK || results[1].File != bad || results[1].OK {
+		t.Fatalf("results = %+v, want %s ok and %s not", results, good, bad)
+	}
+	if len(results[1].Diagnostics) == 0 {
+		t.Error("the level without a title has no diagnostics")
+	}
+}
+
+func TestInfoJSON(t *testing.T) {
+	good, _, done := writeLevels(t)
+	defer done()
+	code, out, _ := runCLI(t, "info", "-json", good)
+	if code != 0 {
+		t.Errorf("exit code %d, want 0", code)
+	}
+	var infos map[string]level.Info
+	if err := json.Unmarshal([]byte(out), &infos); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if info := infos[good]; info.Title != "Good" || info.Blocks != 5 {
+		t.Errorf("info = %+v, want Good with 5 blocks", info)
+	}
+}
+
+func TestDownloadJSON(t *testing.T) {
+	good, _, done := writeLevels(t)
+	defer done()
+	hub := pr2hubtest.NewHub()
+	hub.AddUser("alice", "hunter2")
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+	if code, out, errOut := runCLI(t, "upload", "-hub", srv.URL, "-user", "alice", "-token", hub.Token("alice"), good); code != 0 {
+		t.Fatalf("upload exit code %d: %s%s", code, out, errOut)
+	}
+
+	file := filepath.Join(filepath.Dir(good), "downloaded.txt")
+	for _, out := range []string{"-", file} {
+		code, stdout, errOut := runCLI(t, "download", "-hub", srv.URL, "-json", "-o", out, "1")
+		if code != 0 {
+			t.Fatalf("download -o %s exit code %d: %s", out, code, errOut)
+		}
+		var res downloadResult
+		if err := json.Unmarshal([]byte(stdout), &res); err != nil {
+			t.Fatalf("download -o %s output is not JSON: %v\n%s", out, err, stdout)
+		}
+		if res.ID != "1" || res.Version != "1" || res.Info.Title != "Good" {
+			t.Errorf("download -o %s = %+v, want level 1 version 1 titled Good", out, res)
+		}
+		if out == "-" && (res.Data == "" || res.File != "") {
+			t.Errorf("download to stdout gave file %q and %d bytes of data, want the data", res.File, len(res.Data))
+		}
+		if out == file && (res.Data != "" || res.File != file) {
+			t.Errorf("download to a file gave file %q and %d bytes of data, want the file", res.File, len(res.Data))
+		}
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Error(err)
+	}
+	if code, _, _ := runCLI(t, "download", "-hub", srv.URL, "-json", "99"); code != 1 {
+		t.Errorf("downloading a missing level exit code %d, want 1", code)
+	}
+}
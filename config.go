@@ -2,12 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/fourst4r/levedit/pr2hub"
 )
 
-var configPath string
+var configDir, configPath string
+
+// errLocked is returned by save when a new token can't be encrypted
+// because the config wasn't unlocked.
+var errLocked = errors.New("the saved logins are locked, unlock them to save a new one")
 
 func init() {
 	dir, err := os.UserConfigDir()
@@ -20,29 +30,206 @@ func init() {
 	if err != nil {
 		log.Println(err)
 	}
+	configDir = dir
 	configPath = filepath.Join(dir, "config.json")
 }
 
 func LoadConfig() (*Config, error) {
-	var cfg Config
-	f, err := os.OpenFile(configPath, os.O_RDONLY, 0700)
+	return loadConfig(configPath)
+}
+
+// loadConfig reads the config at path. A corrupt config is moved aside and
+// the backup made by the last save is used instead.
+func loadConfig(path string) (*Config, error) {
+	unlock, err := lockFile(path + ".lock")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return &cfg, nil
-		}
 		return nil, err
 	}
-	defer f.Close()
-	log.Println("Loading config from", configPath)
-	err = json.NewDecoder(f).Decode(&cfg)
-	return &cfg, err
+	defer unlock()
+
+	log.Println("Loading config from", path)
+	cfg, err := readConfig(path)
+	if err == nil {
+		return cfg, nil
+	}
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if _, ok := err.(*os.PathError); ok {
+		return nil, err
+	}
+
+	log.Printf("Config %s is corrupt: %v\n", path, err)
+	if err := os.Rename(path, path+".corrupt"); err != nil {
+		log.Println(err)
+	}
+	backup, berr := readConfig(path + ".bak")
+	if berr != nil {
+		return &Config{}, fmt.Errorf("config is corrupt and has no usable backup: %v", err)
+	}
+	log.Println("Recovered config from", path+".bak")
+	if err := writeFileAtomic(path, mustMarshal(backup), 0600); err != nil {
+		log.Println(err)
+	}
+	return backup, nil
 }
 
-type Acc struct{ User, Token string }
+func readConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	cfg.loaded = cfg.users()
+	return &cfg, nil
+}
+
+type Acc struct {
+	User string
+	// Token is only ever kept in memory, it's saved encrypted as Sealed.
+	Token  string `json:"-"`
+	Sealed []byte `json:",omitempty"`
+	// Plain is where configs from before encryption kept the token.
+	Plain string `json:"Token,omitempty"`
+	// expired is set once the hub no longer accepts Token.
+	expired bool
+}
 
 type Config struct {
 	Accs        []Acc
 	SelectedAcc int
+	// Hub is the address of the pr2hub server, if not the official one.
+	Hub string `json:",omitempty"`
+	// Library is the directory of the level library, if not the default
+	// one in the config directory.
+	Library string `json:",omitempty"`
+	// Salt is set when the tokens are encrypted with a key derived from a
+	// passphrase rather than the key file.
+	Salt []byte `json:",omitempty"`
+
+	// vault encrypts the tokens on save once they've been unlocked.
+	vault *vault
+	// loaded are the users that were saved when the config was read, so
+	// that accounts added by other editors since can be told apart from
+	// the ones removed in this one.
+	loaded []string
+}
+
+// unlock decrypts the tokens with v, which then encrypts them on save. If
+// any token was sealed with another key, it is left empty and v is not
+// kept.
+func (c *Config) unlock(v *vault) error {
+	var failed error
+	for i := range c.Accs {
+		acc := &c.Accs[i]
+		switch {
+		case acc.Sealed != nil:
+			token, err := v.open(acc.Sealed)
+			if err != nil {
+				failed = err
+				continue
+			}
+			acc.Token = token
+		case acc.Plain != "":
+			acc.Token = acc.Plain
+		}
+		redact(acc.Token)
+	}
+	if failed != nil {
+		return failed
+	}
+	c.vault = v
+	return nil
+}
+
+// plaintext reports whether any token is saved unencrypted.
+func (c *Config) plaintext() bool {
+	for _, acc := range c.Accs {
+		if acc.Plain != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// locked reports whether new tokens can't be encrypted yet.
+func (c *Config) locked() bool {
+	return c.vault == nil
+}
+
+// seal encrypts the tokens for saving. Tokens that are still locked keep
+// what they were sealed as. Without a vault a new token would be lost, so
+// that is an error.
+func (c *Config) seal() error {
+	if c.vault == nil {
+		for _, acc := range c.Accs {
+			if acc.Token != "" && acc.Sealed == nil && acc.Plain == "" {
+				return errLocked
+			}
+		}
+		return nil
+	}
+	for i := range c.Accs {
+		acc := &c.Accs[i]
+		if acc.Token != "" {
+			acc.Sealed = c.vault.seal(acc.Token)
+		}
+		acc.Plain = ""
+	}
+	return nil
+}
+
+func (c *Config) users() []string {
+	users := make([]string, len(c.Accs))
+	for i, acc := range c.Accs {
+		users[i] = acc.User
+	}
+	return users
+}
+
+// merge adds the accounts that other editors saved to disk since c was
+// loaded. Accounts that were removed from c stay removed.
+func (c *Config) merge(disk *Config) {
+	known := func(users []string, user string) bool {
+		for _, u := range users {
+			if strings.EqualFold(u, user) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, acc := range disk.Accs {
+		if known(c.users(), acc.User) || known(c.loaded, acc.User) {
+			continue
+		}
+		if acc.Plain != "" {
+			acc.Token, acc.Plain = acc.Plain, ""
+		} else if c.vault != nil && acc.Sealed != nil {
+			if token, err := c.vault.open(acc.Sealed); err == nil {
+				acc.Token = token
+				redact(token)
+			}
+		}
+		log.Println("Keeping the login of", acc.User, "saved by another editor")
+		c.Accs = append(c.Accs, acc)
+	}
+}
+
+func (c *Config) hub() string {
+	if c.Hub == "" {
+		return pr2hub.DefaultBaseURL
+	}
+	return c.Hub
+}
+
+func (c *Config) libraryDir() string {
+	if c.Library == "" {
+		return filepath.Join(configDir, "library")
+	}
+	return c.Library
 }
 
 func (c *Config) selectedAcc() int {
@@ -53,11 +240,45 @@ func (c *Config) selectedAcc() int {
 }
 
 func (c *Config) Save() error {
-	f, err := os.OpenFile(configPath, os.O_CREATE|os.O_WRONLY, 0700)
+	return c.save(configPath)
+}
+
+// save replaces the config at path in one go, keeping the one it replaces
+// as a backup. The lock keeps other editors from saving at the same time,
+// and the logins they saved since c was loaded are kept.
+func (c *Config) save(path string) error {
+	unlock, err := lockFile(path + ".lock")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	log.Println("Saving config to", configPath)
-	return json.NewEncoder(f).Encode(c)
+	defer unlock()
+
+	log.Println("Saving config to", path)
+	old, oldErr := readConfig(path)
+	if oldErr == nil {
+		c.merge(old)
+	}
+	if err := c.seal(); err != nil {
+		return err
+	}
+	data := mustMarshal(c)
+	c.loaded = c.users()
+	// don't keep a backup of plaintext tokens around
+	backup := data
+	if oldErr == nil && !old.plaintext() {
+		backup = mustMarshal(old)
+	}
+	if err := writeFileAtomic(path+".bak", backup, 0600); err != nil {
+		log.Println(err)
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+func mustMarshal(c *Config) []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		// a Config is only strings, bytes and ints
+		panic(err)
+	}
+	return append(b, '\n')
 }
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func tempConfig(t *testing.T) (path string, done func()) {
+	dir, err := ioutil.TempDir("", "levedit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "config.json"), func() { os.RemoveAll(dir) }
+}
+
+func testVault(t *testing.T, key byte) *vault {
+	v, err := newVault(bytes.Repeat([]byte{key}, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// logins lists the accounts of c as user:token.
+func logins(c *Config) []string {
+	var l []string
+	for _, acc := range c.Accs {
+		l = append(l, acc.User+":"+acc.Token)
+	}
+	return l
+}
+
+// readLogins reads the config at path and decrypts its tokens with v.
+func readLogins(t *testing.T, path string, v *vault) []string {
+	c, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.unlock(v); err != nil {
+		t.Fatal(err)
+	}
+	return logins(c)
+}
+
+func TestConfigSave(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	v := testVault(t, 1)
+
+	c := &Config{Accs: []Acc{{User: "alice", Token: "token1"}, {User: "bob", Token: "token2"}}, vault: v}
+	long := logins(c)
+	for _, n := range []int{2, 1} {
+		// removing bob makes the config shorter, which must not leave the
+		// end of the last one behind
+		c.Accs = c.Accs[:n]
+		if err := c.save(path); err != nil {
+			t.Fatal(err)
+		}
+		if got := readLogins(t, path, v); !reflect.DeepEqual(got, logins(c)) {
+			t.Errorf("saved %v, read back %v", logins(c), got)
+		}
+	}
+	if got := readLogins(t, path+".bak", v); !reflect.DeepEqual(got, long) {
+		t.Errorf("backup is %v, want %v", got, long)
+	}
+}
+
+func TestConfigSaveMerges(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	v := testVault(t, 1)
+	if err := (&Config{Accs: []Acc{{User: "alice", Token: "token1"}}, vault: v}).save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// two editors load the config, then each logs in someone else
+	var editors [2]*Config
+	for i := range editors {
+		c, err := loadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.unlock(v); err != nil {
+			t.Fatal(err)
+		}
+		editors[i] = c
+	}
+	a, b := editors[0], editors[1]
+	a.Accs = append(a.Accs, Acc{User: "bob", Token: "token2"})
+	if err := a.save(path); err != nil {
+		t.Fatal(err)
+	}
+	b.Accs = append(b.Accs, Acc{User: "carol", Token: "token3"})
+	if err := b.save(path); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"alice:token1", "carol:token3", "bob:token2"}
+	if got := readLogins(t, path, v); !reflect.DeepEqual(got, want) {
+		t.Errorf("after both saved, config is %v, want %v", got, want)
+	}
+	if got := logins(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("the second editor has %v, want %v", got, want)
+	}
+
+	// an account removed in one editor stays removed
+	a.Accs = a.Accs[1:]
+	if err := a.save(path); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"bob:token2", "carol:token3"}
+	if got := readLogins(t, path, v); !reflect.DeepEqual(got, want) {
+		t.Errorf("after removing alice, config is %v, want %v", got, want)
+	}
+}
+
+func TestConfigTokens(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	// a config from before tokens were encrypted
+	legacy := `{"Accs":[{"User":"alice","Token":"secret1"}],"SelectedAcc":0}`
+	if err := ioutil.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := testVault(t, 1)
+	if err := c.unlock(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{path, path + ".bak"} {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(b, []byte("secret1")) {
+			t.Errorf("%s holds the token in plaintext: %s", filepath.Base(p), b)
+		}
+	}
+	if got, want := readLogins(t, path, v), []string{"alice:secret1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read back %v, want %v", got, want)
+	}
+
+	c, err = readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.unlock(testVault(t, 2)); err == nil {
+		t.Error("unlock() with the wrong key succeeded")
+	}
+	if c.Accs[0].Token != "" || c.vault != nil {
+		t.Error("unlock() with the wrong key kept the token or the key")
+	}
+}
+
+func TestConfigSaveLocked(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	v := testVault(t, 1)
+	if err := (&Config{Accs: []Acc{{User: "alice", Token: "token1"}}, vault: v}).save(path); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the unlock was skipped, so a new token can't be encrypted
+	c.Accs = append(c.Accs, Acc{User: "bob", Token: "token2"})
+	if err := c.save(path); err != errLocked {
+		t.Errorf("save() = %v, want %v", err, errLocked)
+	}
+	if got, want := readLogins(t, path, v), []string{"alice:token1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the refused save, config is %v, want %v", got, want)
+	}
+
+	// changes that keep the locked tokens as they were still save
+	c.Accs = c.Accs[:1]
+	c.SelectedAcc = 0
+	if err := c.save(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readLogins(t, path, v), []string{"alice:token1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("config is %v, want %v", got, want)
+	}
+}
+
+func TestLoadConfigInterrupted(t *testing.T) {
+	v := testVault(t, 1)
+	old := &Config{Accs: []Acc{{User: "alice", Token: "token1"}}, vault: v}
+	cur := &Config{Accs: []Acc{{User: "alice", Token: "token1"}, {User: "bob", Token: "token2"}}, vault: v}
+	tests := []struct {
+		name string
+		// interrupt breaks the config after it was saved as cur
+		interrupt func(path string) error
+		want      *Config
+	}{
+		{"complete", func(string) error { return nil }, cur},
+		{"truncated", func(path string) error {
+			return os.Truncate(path, 10)
+		}, old},
+		{"empty", func(path string) error {
+			return os.Truncate(path, 0)
+		}, old},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, done := tempConfig(t)
+			defer done()
+			for _, c := range []*Config{old, cur} {
+				if err := c.save(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.interrupt(path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := got.unlock(v); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(logins(got), logins(tt.want)) {
+				t.Errorf("loadConfig() = %v, want %v", logins(got), logins(tt.want))
+			}
+			// the recovered config is saved so the next load finds it
+			if again := readLogins(t, path, v); !reflect.DeepEqual(again, logins(tt.want)) {
+				t.Errorf("config after loading is %v, want %v", again, logins(tt.want))
+			}
+		})
+	}
+}
+
+func TestSaveConfigInterrupted(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	v := testVault(t, 1)
+	old := &Config{Accs: []Acc{{User: "alice", Token: "token1"}}, vault: v}
+	if err := old.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the editor dies after writing the new config, before the rename
+	renameFile = func(string, string) error { return errors.New("killed") }
+	defer func() { renameFile = os.Rename }()
+	cur := &Config{Accs: []Acc{{User: "alice", Token: "token1"}, {User: "bob", Token: "token2"}}, vault: v}
+	if err := cur.save(path); err == nil {
+		t.Fatal("save() succeeded without renaming")
+	}
+	renameFile = os.Rename
+
+	got, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.unlock(v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(logins(got), logins(old)) {
+		t.Errorf("loadConfig() = %v, want %v", logins(got), logins(old))
+	}
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) > 0 {
+		t.Errorf("the interrupted save left %v behind", files)
+	}
+}
+
+func TestLoadConfigNoBackup(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	if err := ioutil.WriteFile(path, []byte(`{"Accs":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("loadConfig() of a corrupt config without a backup succeeded")
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("corrupt config was not kept: %v", err)
+	}
+}
+
+func Test_lockFile(t *testing.T) {
+	path, done := tempConfig(t)
+	defer done()
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := lockFile(path + ".lock")
+		if err != nil {
+			t.Error(err)
+		} else {
+			unlock()
+		}
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lockFile() did not wait for the lock to be released")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("lockFile() still waiting after the lock was released")
+	}
+}
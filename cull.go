@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+
+	"github.com/fourst4r/course"
+	"github.com/hajimehoshi/ebiten"
+)
+
+// chunkSize is the width and height of an index chunk, in blocks.
+const chunkSize = 16
+
+type chunkKey struct{ x, y int }
+
+// chunkOf returns the chunk holding the block unit coordinates x, y.
+func chunkOf(x, y int) chunkKey {
+	return chunkKey{floorDiv(x, chunkSize), floorDiv(y, chunkSize)}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// blockIndex groups cells into square chunks so the cells in view can be
+// found without going through the whole course. It may hold cells that
+// have since been emptied, so callers look the stacks up themselves.
+type blockIndex struct {
+	chunks map[chunkKey][]course.XY
+}
+
+func newBlockIndex() *blockIndex {
+	return &blockIndex{chunks: make(map[chunkKey][]course.XY)}
+}
+
+// has reports whether xy is indexed.
+func (ix *blockIndex) has(xy course.XY) bool {
+	for _, c := range ix.chunks[chunkOf(cell(xy.X), cell(xy.Y))] {
+		if c == xy {
+			return true
+		}
+	}
+	return false
+}
+
+// add indexes xy, unless it already is.
+func (ix *blockIndex) add(xy course.XY) {
+	if !ix.has(xy) {
+		ix.insert(xy)
+	}
+}
+
+// insert indexes xy, which must not be indexed yet.
+func (ix *blockIndex) insert(xy course.XY) {
+	k := chunkOf(cell(xy.X), cell(xy.Y))
+	ix.chunks[k] = append(ix.chunks[k], xy)
+}
+
+// each calls fn for the cells in the chunks that overlap the block unit
+// rectangle x0,y0 x1,y1, which may include some cells just outside it.
+func (ix *blockIndex) each(x0, y0, x1, y1 int, fn func(xy course.XY)) {
+	c0, c1 := chunkOf(x0, y0), chunkOf(x1, y1)
+	for cy := c0.y; cy <= c1.y; cy++ {
+		for cx := c0.x; cx <= c1.x; cx++ {
+			for _, xy := range ix.chunks[chunkKey{cx, cy}] {
+				fn(xy)
+			}
+		}
+	}
+}
+
+// cellsOf returns the cells whose blocks ed changes.
+func cellsOf(ed edit) []course.XY {
+	var be *blocksEdit
+	switch ed := ed.(type) {
+	case *blocksEdit:
+		be = ed
+	case *restoreEdit:
+		be = ed.blocks
+	default:
+		return nil
+	}
+	cells := make([]course.XY, 0, len(be.after))
+	for xy := range be.after {
+		cells = append(cells, xy)
+	}
+	return cells
+}
+
+// touched updates what follows the course after ed was applied, undone
+// or redone, without going through the whole course again.
+func (e *Editor) touched(ed edit) {
+	cells := cellsOf(ed)
+	if e.index != nil && e.indexed == e.Course {
+		for _, xy := range cells {
+			e.index.add(xy)
+		}
+	}
+	for _, xy := range cells {
+		e.minimapCell(xy)
+	}
+}
+
+// blockIndex returns the index of the course, building it if the course
+// was replaced. Edits are added to it as they are made by touched, but a
+// stroke in progress is not in the index.
+func (e *Editor) blockIndex() *blockIndex {
+	if e.index == nil || e.indexed != e.Course {
+		e.index = newBlockIndex()
+		for xy := range e.Course.Blocks {
+			e.index.insert(xy)
+		}
+		e.indexed = e.Course
+	}
+	return e.index
+}
+
+// eachVisible calls fn for every cell on screen that may hold blocks,
+// including the ones painted by the current stroke.
+func (e *Editor) eachVisible(screen *ebiten.Image, fn func(xy course.XY)) {
+	x0, y0, x1, y1, ok := e.visibleCells(screen)
+	if !ok {
+		return
+	}
+	e.eachIn(int(math.Floor(x0)), int(math.Floor(y0)), int(math.Floor(x1)), int(math.Floor(y1)), fn)
+}
+
+// eachIn calls fn once for every cell that may hold blocks in the block
+// unit rectangle x0,y0 x1,y1, like blockIndex.each.
+func (e *Editor) eachIn(x0, y0, x1, y1 int, fn func(xy course.XY)) {
+	ix := e.blockIndex()
+	ix.each(x0, y0, x1, y1, fn)
+	if e.stroke == nil {
+		return
+	}
+	for xy := range e.stroke.after {
+		if ix.has(xy) {
+			continue
+		}
+		if x, y := cell(xy.X), cell(xy.Y); x >= x0 && x <= x1 && y >= y0 && y <= y1 {
+			fn(xy)
+		}
+	}
+}
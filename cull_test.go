@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/fourst4r/course"
+)
+
+func Test_floorDiv(t *testing.T) {
+	tests := []struct{ a, b, want int }{
+		{0, 16, 0},
+		{15, 16, 0},
+		{16, 16, 1},
+		{-1, 16, -1},
+		{-16, 16, -1},
+		{-17, 16, -2},
+	}
+	for _, tt := range tests {
+		if got := floorDiv(tt.a, tt.b); got != tt.want {
+			t.Errorf("floorDiv(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func Test_blockIndex(t *testing.T) {
+	ix := newBlockIndex()
+	// cells added twice are only indexed once
+	for _, xy := range cells(0, 0, 15, 15, 16, 0, -1, -1, 100, 100, 0, 0) {
+		ix.add(xy)
+	}
+	tests := []struct {
+		name           string
+		x0, y0, x1, y1 int
+		want           []course.XY
+	}{
+		{"one chunk", 2, 2, 3, 3, cells(0, 0, 15, 15)},
+		{"across chunks", 10, 0, 20, 5, cells(0, 0, 15, 15, 16, 0)},
+		{"negative", -3, -3, -2, -2, cells(-1, -1)},
+		{"empty", 40, 40, 50, 50, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []course.XY
+			ix.each(tt.x0, tt.y0, tt.x1, tt.y1, func(xy course.XY) {
+				got = append(got, xy)
+			})
+			sortCells(got)
+			sortCells(tt.want)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("each() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorTouched(t *testing.T) {
+	e := &Editor{Course: &course.Course{}}
+	ix := e.blockIndex()
+	e.touched(&blocksEdit{after: map[course.XY][]int{cells(3, 4)[0]: {1}}})
+	e.touched(&textEdit{name: "title"})
+	if e.blockIndex() != ix {
+		t.Fatal("the index was rebuilt after an edit")
+	}
+	var got []course.XY
+	ix.each(0, 0, 10, 10, func(xy course.XY) { got = append(got, xy) })
+	if want := cells(3, 4); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("indexed %v, want %v", got, want)
+	}
+
+	e.Course = &course.Course{}
+	if e.blockIndex() == ix {
+		t.Error("the index wasn't rebuilt for a new course")
+	}
+}
+
+func TestEditorEachIn(t *testing.T) {
+	a, b, c := cells(1, 1)[0], cells(2, 2)[0], cells(3, 3)[0]
+	e := &Editor{Course: courseOf(map[course.XY][]int{a: {1}})}
+	// b held blocks that have since been erased, so it is still indexed
+	e.blockIndex().add(b)
+	e.stroke = &blocksEdit{
+		before: map[course.XY][]int{a: {1}, b: nil, c: nil},
+		after:  map[course.XY][]int{a: {1, 2}, b: {3}, c: {4}},
+	}
+	seen := make(map[course.XY]int)
+	e.eachIn(0, 0, 10, 10, func(xy course.XY) { seen[xy]++ })
+	if want := map[course.XY]int{a: 1, b: 1, c: 1}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("eachIn() called fn for %v, want each cell once", seen)
+	}
+}
+
+func sortCells(c []course.XY) {
+	sort.Slice(c, func(i, j int) bool {
+		if c[i].Y != c[j].Y {
+			return c[i].Y < c[j].Y
+		}
+		return c[i].X < c[j].X
+	})
+}
+
+// BenchmarkVisible looks up a screenful of blocks in ever larger courses,
+// which should take about as long whatever the size.
+func BenchmarkVisible(b *testing.B) {
+	for _, side := range []int{100, 300, 1000} {
+		ix := filledIndex(side)
+		b.Run(fmt.Sprintf("%d blocks", side*side), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				n := 0
+				// a 1280x720 screen at zoom 1
+				ix.each(40, 40, 40+1280/tileSize, 40+720/tileSize, func(course.XY) { n++ })
+			}
+		})
+	}
+}
+
+// BenchmarkAllBlocks is how the editor used to find the blocks to draw.
+func BenchmarkAllBlocks(b *testing.B) {
+	for _, side := range []int{100, 300, 1000} {
+		blocks := make(map[course.XY]bool)
+		for y := 0; y < side; y++ {
+			for x := 0; x < side; x++ {
+				blocks[course.XY{X: x * tileSize, Y: y * tileSize}] = true
+			}
+		}
+		b.Run(fmt.Sprintf("%d blocks", side*side), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				n := 0
+				for range blocks {
+					n++
+				}
+			}
+		})
+	}
+}
+
+// filledIndex indexes a side by side square of blocks.
+func filledIndex(side int) *blockIndex {
+	ix := newBlockIndex()
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			ix.insert(course.XY{X: x * tileSize, Y: y * tileSize})
+		}
+	}
+	return ix
+}
+
+// BenchmarkEdit is what keeping the index up to date costs per edit, which
+// should stay flat as courses grow.
+func BenchmarkEdit(b *testing.B) {
+	for _, side := range []int{100, 300, 1000} {
+		ix := filledIndex(side)
+		b.Run(fmt.Sprintf("%d blocks", side*side), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// painting over a block and next to the course
+				ix.add(course.XY{X: (i % side) * tileSize, Y: 0})
+				ix.add(course.XY{X: (i % side) * tileSize, Y: -tileSize})
+			}
+		})
+	}
+}
+
+// BenchmarkRebuild is what every edit cost when the index was rebuilt.
+func BenchmarkRebuild(b *testing.B) {
+	for _, side := range []int{100, 300} {
+		b.Run(fmt.Sprintf("%d blocks", side*side), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				filledIndex(side)
+			}
+		})
+	}
+}
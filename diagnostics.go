@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// validate checks the course and shows what was found.
+func (e *Editor) validate() {
+	e.diagnostics = level.Validate(e.current())
+	e.showDiagnostics = true
+}
+
+func (e *Editor) diagnosticsWindow() {
+	if !e.showDiagnostics {
+		return
+	}
+	if imgui.BeginV("Diagnostics", &e.showDiagnostics, imgui.WindowFlagsNone) {
+		if imgui.Button("Check again") {
+			e.validate()
+		}
+		imgui.Separator()
+		if len(e.diagnostics) == 0 {
+			imgui.Text("No problems found.")
+		}
+		for i, d := range e.diagnostics {
+			imgui.PushID(strconv.Itoa(i))
+			if imgui.Selectable(d.String()) && d.Located {
+				// go to the cell and show what is stacked there
+				x, y := xytof(d.At)
+				setPos(&e.cam, -x, -y)
+				e.inspector.pinned, e.inspector.cell = true, d.At
+			}
+			imgui.PopID()
+		}
+	}
+	imgui.End()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// exportPNG renders the course to an image file at path.
+func (e *Editor) exportPNG(path string, scale float64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := level.WritePNG(f, e.Course, blocksAtlas, scale); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	log.Println("Exported level to", path)
+	return f.Close()
+}
+
+const (
+	PopupExport        = "Export PNG##PopupExport"
+	PopupExportFailure = "Export failed##PopupExportFailure"
+)
+
+func (e *Editor) exportPopup() {
+	if e.openExport {
+		e.openExport = false
+		dir, name := filepath.Split(e.path)
+		if e.path == "" {
+			name = e.Course.Title
+		}
+		e.files.open(dir)
+		e.files.name = strings.TrimSuffix(name, filepath.Ext(name)) + ".png"
+		imgui.OpenPopup(PopupExport)
+	}
+	// PopupExport
+	imgui.SetNextWindowSize(imgui.Vec2{X: 500, Y: 0})
+	if imgui.BeginPopupModalV(PopupExport, nil, imgui.WindowFlagsNone) {
+		chosen := e.files.draw()
+		imgui.SliderFloat("Scale", &e.exportScale, 0.1, 2)
+		if (imgui.Button("Export") || chosen) && e.files.name != "" {
+			if err := e.exportPNG(e.files.path(), float64(e.exportScale)); err != nil {
+				e.fileerr = err.Error()
+				log.Println(err)
+				defer imgui.OpenPopup(PopupExportFailure)
+			}
+			imgui.CloseCurrentPopup()
+		}
+		imgui.SameLine()
+		if imgui.Button("Cancel") {
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+	// PopupExportFailure
+	if imgui.BeginPopupModalV(PopupExportFailure, nil, imgui.WindowFlagsAlwaysAutoResize) {
+		imgui.Text(e.fileerr)
+		if imgui.Button("OK") {
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+}
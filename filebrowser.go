@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// fileBrowser lists a directory inside an imgui window so that files can
+// be picked without a native dialog.
+type fileBrowser struct {
+	dir     string
+	entries []os.FileInfo
+	err     error
+	// name is the file name typed or clicked by the user
+	name string
+}
+
+// open points the browser at dir, or the working directory if dir is empty.
+func (b *fileBrowser) open(dir string) {
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	b.dir = dir
+	b.refresh()
+}
+
+func (b *fileBrowser) refresh() {
+	b.entries, b.err = ioutil.ReadDir(b.dir)
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		// directories first
+		return b.entries[i].IsDir() && !b.entries[j].IsDir()
+	})
+}
+
+// path returns the full path of the chosen file.
+func (b *fileBrowser) path() string {
+	if filepath.IsAbs(b.name) {
+		return b.name
+	}
+	return filepath.Join(b.dir, b.name)
+}
+
+// draw draws the browser into the current window. It reports true when
+// a file was double clicked.
+func (b *fileBrowser) draw() (chosen bool) {
+	imgui.PushTextWrapPos()
+	imgui.Text(b.dir)
+	imgui.PopTextWrapPos()
+
+	if imgui.BeginChildV("files", imgui.Vec2{X: 0, Y: 300}, true, imgui.WindowFlagsNone) {
+		if imgui.Selectable("../") {
+			b.open(filepath.Dir(b.dir))
+		}
+		for _, fi := range b.entries {
+			name := fi.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if fi.IsDir() {
+				if imgui.Selectable(name + "/") {
+					// reading the new dir replaces b.entries, so stop here
+					b.open(filepath.Join(b.dir, name))
+					break
+				}
+				continue
+			}
+			if imgui.SelectableV(name, name == b.name, imgui.SelectableFlagsAllowDoubleClick, imgui.Vec2{}) {
+				b.name = name
+				if imgui.IsMouseDoubleClicked(0) {
+					chosen = true
+				}
+			}
+		}
+		if b.err != nil {
+			imgui.Text(b.err.Error())
+		}
+	}
+	imgui.EndChild()
+
+	imgui.InputText("File", &b.name)
+	return chosen
+}
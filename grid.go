@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+const (
+	// minGridGap is how close grid lines get on screen, in pixels.
+	minGridGap = 8
+	// minLabelGap keeps the ruler labels from running into each other.
+	minLabelGap = 40
+	// rulerSize is the width of the rulers along the screen edges.
+	rulerSize = 16
+)
+
+// gridSteps are the block spacings the grid can be drawn at, finest first.
+var gridSteps = []int{1, 5, 10, 50, 100, 500, 1000}
+
+var (
+	gridColor  = color.NRGBA{0x80, 0x80, 0x80, 0x60}
+	rulerColor = color.NRGBA{0x20, 0x20, 0x20, 0xc0}
+)
+
+// gridStep returns how many blocks apart lines must be to stay at least
+// gap pixels apart at zoom.
+func gridStep(zoom, gap float64) int {
+	for _, step := range gridSteps {
+		if float64(step)*tileSize*zoom >= gap {
+			return step
+		}
+	}
+	return gridSteps[len(gridSteps)-1]
+}
+
+// gridLines returns the multiples of step from the one at or below lo up
+// to hi, in block units.
+func gridLines(lo, hi float64, step int) []int {
+	first := int(math.Floor(lo/float64(step))) * step
+	var lines []int
+	for v := first; float64(v) <= hi; v += step {
+		lines = append(lines, v)
+	}
+	return lines
+}
+
+// visibleCells returns the screen bounds in block units.
+func (e *Editor) visibleCells(screen *ebiten.Image) (x0, y0, x1, y1 float64, ok bool) {
+	w, h := screen.Size()
+	x0, y0 = e.screenToWorld(screen, 0, 0)
+	x1, y1 = e.screenToWorld(screen, float64(w), float64(h))
+	if math.IsNaN(x0) || math.IsNaN(x1) {
+		return 0, 0, 0, 0, false
+	}
+	return x0 / tileSize, y0 / tileSize, x1 / tileSize, y1 / tileSize, true
+}
+
+// drawGrid draws a line between cells, or between every few cells when
+// zoomed out.
+func (e *Editor) drawGrid(screen *ebiten.Image, cam ebiten.GeoM) {
+	x0, y0, x1, y1, ok := e.visibleCells(screen)
+	if !ok {
+		return
+	}
+	w, h := screen.Size()
+	step := gridStep(e.zoom, minGridGap)
+	for _, x := range gridLines(x0, x1, step) {
+		sx, _ := cam.Apply(float64(x*tileSize), 0)
+		ebitenutil.DrawLine(screen, sx, 0, sx, float64(h), gridColor)
+	}
+	for _, y := range gridLines(y0, y1, step) {
+		_, sy := cam.Apply(0, float64(y*tileSize))
+		ebitenutil.DrawLine(screen, 0, sy, float64(w), sy, gridColor)
+	}
+}
+
+// drawRulers numbers the cells along the top and left of the screen.
+func (e *Editor) drawRulers(screen *ebiten.Image, cam ebiten.GeoM) {
+	x0, y0, x1, y1, ok := e.visibleCells(screen)
+	if !ok {
+		return
+	}
+	w, h := screen.Size()
+	ebitenutil.DrawRect(screen, 0, 0, float64(w), rulerSize, rulerColor)
+	ebitenutil.DrawRect(screen, 0, rulerSize, rulerSize*2, float64(h-rulerSize), rulerColor)
+
+	step := gridStep(e.zoom, minLabelGap)
+	for _, x := range gridLines(x0, x1, step) {
+		sx, _ := cam.Apply(float64(x*tileSize), 0)
+		ebitenutil.DrawLine(screen, sx, rulerSize/2, sx, rulerSize, color.White)
+		ebitenutil.DebugPrintAt(screen, fmt.Sprint(x), int(sx)+2, 0)
+	}
+	for _, y := range gridLines(y0, y1, step) {
+		_, sy := cam.Apply(0, float64(y*tileSize))
+		if sy < rulerSize {
+			continue
+		}
+		ebitenutil.DrawLine(screen, rulerSize, sy, rulerSize*2, sy, color.White)
+		ebitenutil.DebugPrintAt(screen, fmt.Sprint(y), 0, int(sy))
+	}
+}
+
+// statusBar shows the hovered cell and the selected block.
+func (e *Editor) statusBar() {
+	flags := imgui.WindowFlagsNoCollapse | imgui.WindowFlagsNoTitleBar |
+		imgui.WindowFlagsAlwaysAutoResize
+	if imgui.BeginV("Status", nil, flags) {
+		imgui.Text(fmt.Sprintf("Cell %d,%d (pixel %d,%d)  Block: %s",
+			cell(e.hover.X), cell(e.hover.Y), e.hover.X, e.hover.Y, level.BlockName(int(e.block))))
+		imgui.SameLine()
+		imgui.Checkbox("Grid (H)", &e.showGrid)
+		if e.tool == toolFill && e.fillPreview().tooLarge {
+			imgui.SameLine()
+			imgui.Text("area too large to fill")
+		}
+		if e.readOnly {
+			imgui.SameLine()
+			if imgui.Button("Edit a copy") {
+				e.readOnly = false
+				e.history.unsaved = true
+			}
+		}
+	}
+	imgui.End()
+}
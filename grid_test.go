@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_gridStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		zoom, gap float64
+		want      int
+	}{
+		{"every cell", 1, minGridGap, 1},
+		{"zoomed out", 0.05, minGridGap, 10},
+		{"labels", 1, minLabelGap, 5},
+		{"far out", 0.0001, minGridGap, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gridStep(tt.zoom, tt.gap); got != tt.want {
+				t.Errorf("gridStep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_gridLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		lo, hi float64
+		step   int
+		want   []int
+	}{
+		{"cells", -1.5, 1.5, 1, []int{-2, -1, 0, 1}},
+		{"steps", -7, 12, 5, []int{-10, -5, 0, 5, 10}},
+		{"on a line", 10, 10, 10, []int{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gridLines(tt.lo, tt.hi, tt.step); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gridLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+)
+
+// edit is a reversible change to a course.
+type edit interface {
+	apply(c *course.Course)
+	revert(c *course.Course)
+}
+
+// merger is implemented by edits that can absorb the edit that follows
+// them, so that e.g. dragging a color picker makes a single undo step.
+type merger interface {
+	merge(next edit) bool
+}
+
+type history struct {
+	undos, redos []edit
+	// sealed stops the next edit from merging into the last one.
+	sealed bool
+	// saved is the last edit at the time the course was saved.
+	saved edit
+	// unsaved marks a course that was never saved, like a restored one.
+	unsaved bool
+	// changes counts every change to the course, including the ones that
+	// merge into the last edit, and is never reset.
+	changes int
+}
+
+// do applies ed to c and records it.
+func (h *history) do(c *course.Course, ed edit) {
+	ed.apply(c)
+	h.push(ed)
+}
+
+// push records ed, which has already been applied.
+func (h *history) push(ed edit) {
+	h.redos = nil
+	h.changes++
+	if n := len(h.undos); n > 0 && !h.sealed {
+		if m, ok := h.undos[n-1].(merger); ok && m.merge(ed) {
+			return
+		}
+	}
+	h.undos = append(h.undos, ed)
+	h.sealed = false
+}
+
+func (h *history) undo(c *course.Course) bool {
+	n := len(h.undos)
+	if n == 0 {
+		return false
+	}
+	ed := h.undos[n-1]
+	h.undos = h.undos[:n-1]
+	ed.revert(c)
+	h.redos = append(h.redos, ed)
+	h.sealed = true
+	h.changes++
+	return true
+}
+
+func (h *history) redo(c *course.Course) bool {
+	n := len(h.redos)
+	if n == 0 {
+		return false
+	}
+	ed := h.redos[n-1]
+	h.redos = h.redos[:n-1]
+	ed.apply(c)
+	h.undos = append(h.undos, ed)
+	h.sealed = true
+	h.changes++
+	return true
+}
+
+// changed records a change that isn't an edit, like to the settings.
+func (h *history) changed() {
+	h.changes++
+	h.unsaved = true
+}
+
+func (h *history) seal() {
+	h.sealed = true
+}
+
+func (h *history) reset() {
+	h.undos, h.redos = nil, nil
+	h.sealed = false
+	h.saved = nil
+	h.unsaved = false
+}
+
+func (h *history) last() edit {
+	if n := len(h.undos); n > 0 {
+		return h.undos[n-1]
+	}
+	return nil
+}
+
+// markSaved records that the course is saved as it is now.
+func (h *history) markSaved() {
+	h.saved = h.last()
+	h.sealed = true
+	h.unsaved = false
+}
+
+// dirty reports whether the course changed since it was last saved.
+func (h *history) dirty() bool {
+	return h.unsaved || h.last() != h.saved
+}
+
+// blocksEdit replaces the block stacks of any number of cells.
+type blocksEdit struct {
+	before, after map[course.XY][]int
+}
+
+func newBlocksEdit() *blocksEdit {
+	return &blocksEdit{
+		before: make(map[course.XY][]int),
+		after:  make(map[course.XY][]int),
+	}
+}
+
+// set changes the stack at xy to ids, remembering what was there first.
+func (ed *blocksEdit) set(c *course.Course, xy course.XY, ids []int) {
+	if _, ok := ed.before[xy]; !ok {
+		ed.before[xy] = stackAt(c, xy)
+	}
+	ed.after[xy] = ids
+	setStack(c, xy, ids)
+}
+
+func (ed *blocksEdit) empty() bool {
+	return len(ed.after) == 0
+}
+
+func (ed *blocksEdit) apply(c *course.Course) {
+	for xy, ids := range ed.after {
+		setStack(c, xy, ids)
+	}
+}
+
+func (ed *blocksEdit) revert(c *course.Course) {
+	for xy, ids := range ed.before {
+		setStack(c, xy, ids)
+	}
+}
+
+type bgEdit struct {
+	before, after color.Color
+}
+
+func (ed *bgEdit) apply(c *course.Course)  { c.BackgroundColor = ed.after }
+func (ed *bgEdit) revert(c *course.Course) { c.BackgroundColor = ed.before }
+
+func (ed *bgEdit) merge(next edit) bool {
+	n, ok := next.(*bgEdit)
+	if ok {
+		ed.after = n.after
+	}
+	return ok
+}
+
+// liveEdit publishes or unpublishes the course.
+type liveEdit struct {
+	before, after bool
+}
+
+func (ed *liveEdit) apply(c *course.Course)  { c.Live = ed.after }
+func (ed *liveEdit) revert(c *course.Course) { c.Live = ed.before }
+
+// textEdit changes one of the course's text fields, e.g. the title.
+type textEdit struct {
+	name          string
+	field         func(c *course.Course) *string
+	before, after string
+}
+
+func (ed *textEdit) apply(c *course.Course)  { *ed.field(c) = ed.after }
+func (ed *textEdit) revert(c *course.Course) { *ed.field(c) = ed.before }
+
+func (ed *textEdit) merge(next edit) bool {
+	n, ok := next.(*textEdit)
+	if ok && n.name == ed.name {
+		ed.after = n.after
+		return true
+	}
+	return false
+}
+
+func courseTitle(c *course.Course) *string { return &c.Title }
+func courseNote(c *course.Course) *string  { return &c.Note }
+
+// stackAt returns a copy of the block IDs at xy, bottom first.
+func stackAt(c *course.Course, xy course.XY) []int {
+	stack := c.Blocks[xy]
+	if len(stack) == 0 {
+		return nil
+	}
+	ids := make([]int, len(stack))
+	for i, block := range stack {
+		ids[i] = block.(int)
+	}
+	return ids
+}
+
+func setStack(c *course.Course, xy course.XY, ids []int) {
+	for {
+		if _, ok := c.Blocks.Peek(xy.X, xy.Y); !ok {
+			break
+		}
+		c.Blocks.Pop(xy.X, xy.Y)
+	}
+	delete(c.Blocks, xy)
+	for _, id := range ids {
+		c.Blocks.Push(xy.X, xy.Y, id)
+	}
+}
+
+// artEdit replaces the art of a layer, which lives in the editor rather
+// than the course.
+type artEdit struct {
+	art           *level.Art
+	before, after level.Art
+}
+
+func (ed *artEdit) apply(*course.Course) {
+	*ed.art = ed.after.Clone()
+}
+
+func (ed *artEdit) revert(*course.Course) {
+	*ed.art = ed.before.Clone()
+}
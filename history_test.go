@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fourst4r/course"
+)
+
+func Test_history(t *testing.T) {
+	c := &course.Course{Title: "a"}
+	var h history
+	title := func(s string) edit {
+		return &textEdit{name: "title", field: courseTitle, before: c.Title, after: s}
+	}
+
+	h.do(c, title("ab"))
+	h.do(c, title("abc")) // merges into the previous edit
+	h.seal()
+	h.do(c, title("x"))
+
+	tests := []struct {
+		name string
+		step func(*course.Course) bool
+		ok   bool
+		want string
+	}{
+		{"undo", h.undo, true, "abc"},
+		{"undo merged", h.undo, true, "a"},
+		{"undo empty", h.undo, false, "a"},
+		{"redo", h.redo, true, "abc"},
+		{"redo", h.redo, true, "x"},
+		{"redo empty", h.redo, false, "x"},
+	}
+	for _, tt := range tests {
+		if ok := tt.step(c); ok != tt.ok || c.Title != tt.want {
+			t.Errorf("%s: got (%v, %q), want (%v, %q)", tt.name, ok, c.Title, tt.ok, tt.want)
+		}
+	}
+
+	h.undo(c)
+	h.do(c, title("y"))
+	if h.redo(c) {
+		t.Errorf("redo after a new edit should do nothing")
+	}
+}
+
+func Test_history_dirty(t *testing.T) {
+	c := &course.Course{}
+	var h history
+	title := func(s string) edit {
+		return &textEdit{name: "title", field: courseTitle, before: c.Title, after: s}
+	}
+	if h.dirty() {
+		t.Fatal("new history is dirty")
+	}
+	h.do(c, title("a"))
+	h.markSaved()
+	h.do(c, title("ab")) // must not merge into the saved edit
+	if !h.dirty() {
+		t.Error("edit after save is not dirty")
+	}
+	h.undo(c)
+	if h.dirty() {
+		t.Error("undo back to the save is dirty")
+	}
+	h.undo(c)
+	if !h.dirty() {
+		t.Error("undo past the save is not dirty")
+	}
+}
+
+func Test_liveEdit(t *testing.T) {
+	c := &course.Course{}
+	var h history
+	h.markSaved()
+	h.do(c, &liveEdit{before: false, after: true})
+	if !c.Live || !h.dirty() {
+		t.Errorf("publishing left Live %v and dirty %v, want both true", c.Live, h.dirty())
+	}
+	h.undo(c)
+	if c.Live || h.dirty() {
+		t.Errorf("undoing left Live %v and dirty %v, want both false", c.Live, h.dirty())
+	}
+}
+
+func Test_history_changes(t *testing.T) {
+	c := &course.Course{}
+	var h history
+	title := func(s string) edit {
+		return &textEdit{name: "title", field: courseTitle, before: c.Title, after: s}
+	}
+	steps := []struct {
+		name string
+		step func()
+	}{
+		{"do", func() { h.do(c, title("a")) }},
+		{"merge", func() { h.do(c, title("ab")) }},
+		{"undo", func() { h.undo(c) }},
+		{"redo", func() { h.redo(c) }},
+		{"settings", h.changed},
+	}
+	for _, s := range steps {
+		before := h.changes
+		s.step()
+		if h.changes == before {
+			t.Errorf("%s didn't count as a change", s.name)
+		}
+	}
+	if !h.dirty() {
+		t.Error("a change to the settings is not dirty")
+	}
+}
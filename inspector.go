@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/inkyblackness/imgui-go/v2"
+	"golang.org/x/image/colornames"
+)
+
+// inspector shows the block stack of a cell, which is the hovered cell
+// unless one was pinned with the middle mouse button.
+type inspector struct {
+	pinned bool
+	cell   course.XY
+	// selected is the index of the selected entry of the stack.
+	selected int
+	// stacks marks the cells that hold more than one block.
+	stacks bool
+}
+
+// withEntry returns a copy of stack with id inserted at i.
+func withEntry(stack []int, i, id int) []int {
+	s := make([]int, 0, len(stack)+1)
+	s = append(s, stack[:i]...)
+	s = append(s, id)
+	return append(s, stack[i:]...)
+}
+
+// withoutEntry returns a copy of stack without the entry at i.
+func withoutEntry(stack []int, i int) []int {
+	s := make([]int, 0, len(stack))
+	s = append(s, stack[:i]...)
+	return append(s, stack[i+1:]...)
+}
+
+// swapped returns a copy of stack with the entries i and j swapped.
+func swapped(stack []int, i, j int) []int {
+	s := append([]int(nil), stack...)
+	s[i], s[j] = s[j], s[i]
+	return s
+}
+
+// inspected returns the cell the inspector shows.
+func (e *Editor) inspected() course.XY {
+	if e.inspector.pinned {
+		return e.inspector.cell
+	}
+	return e.hover
+}
+
+// setInspected replaces the stack of the inspected cell.
+func (e *Editor) setInspected(stack []int) {
+	if e.locked(layerBlocks) {
+		return
+	}
+	ed := newBlocksEdit()
+	ed.set(e.Course, e.inspected(), stack)
+	e.do(ed)
+}
+
+func (e *Editor) inspectorWindow() {
+	if !imgui.Begin("Inspector") {
+		imgui.End()
+		return
+	}
+	ins := &e.inspector
+	xy := e.inspected()
+	imgui.Text(fmt.Sprintf("Cell %d,%d (pixel %d,%d)", cell(xy.X), cell(xy.Y), xy.X, xy.Y))
+	if ins.pinned {
+		if imgui.Button("Unpin") {
+			ins.pinned = false
+		}
+	} else {
+		imgui.Text("Middle click a cell to pin it.")
+	}
+	imgui.Checkbox("Mark stacks", &ins.stacks)
+	imgui.Separator()
+
+	stack := stackAt(e.Course, xy)
+	if ins.selected >= len(stack) {
+		ins.selected = len(stack) - 1
+	}
+	if ins.selected < 0 {
+		ins.selected = 0
+	}
+	if len(stack) == 0 {
+		imgui.Text("Empty")
+	}
+	// the top of the stack is drawn last, so list it first
+	for i := len(stack) - 1; i >= 0; i-- {
+		id := stack[i]
+		label := fmt.Sprintf("%d: %s (%d)", i, level.BlockName(id), id)
+		if id > 99 {
+			label += " >100"
+		}
+		if imgui.SelectableV(label, i == ins.selected, 0, imgui.Vec2{}) {
+			ins.selected = i
+		}
+	}
+	imgui.Separator()
+
+	if len(stack) > 0 {
+		i := ins.selected
+		if imgui.Button("Up") && i+1 < len(stack) {
+			e.setInspected(swapped(stack, i, i+1))
+			ins.selected++
+		}
+		imgui.SameLine()
+		if imgui.Button("Down") && i > 0 {
+			e.setInspected(swapped(stack, i, i-1))
+			ins.selected--
+		}
+		imgui.SameLine()
+		if imgui.Button("Delete") {
+			e.setInspected(withoutEntry(stack, i))
+		}
+		imgui.SameLine()
+		if imgui.Button("Toggle >100") {
+			s := append([]int(nil), stack...)
+			if s[i] > 99 {
+				s[i] -= 100
+			} else {
+				s[i] += 100
+			}
+			e.setInspected(s)
+		}
+	}
+	if imgui.Button(fmt.Sprintf("Insert %s", blocks[e.block])) {
+		// above the selected entry, or as the only one
+		i := 0
+		if len(stack) > 0 {
+			i = ins.selected + 1
+		}
+		e.setInspected(withEntry(stack, i, int(e.block)))
+		ins.selected = i
+	}
+	imgui.End()
+}
+
+// drawInspector outlines the inspected cell and marks stacked cells.
+func (e *Editor) drawInspector(screen *ebiten.Image, cam ebiten.GeoM) {
+	if e.inspector.stacks {
+		e.eachVisible(screen, func(xy course.XY) {
+			if stack := e.Course.Blocks[xy]; len(stack) > 1 {
+				x, y := cam.Apply(xytof(xy))
+				ebitenutil.DebugPrintAt(screen, fmt.Sprint(len(stack)), int(x)+2, int(y))
+			}
+		})
+	}
+	if e.inspector.pinned {
+		x, y := xytof(e.inspector.cell)
+		drawWorldRect(screen, cam, x, y, x+tileSize, y+tileSize, colornames.Yellow)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_stackEntries(t *testing.T) {
+	stack := []int{1, 2, 3}
+	tests := []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{"insert bottom", withEntry(stack, 0, 9), []int{9, 1, 2, 3}},
+		{"insert top", withEntry(stack, 3, 9), []int{1, 2, 3, 9}},
+		{"insert middle", withEntry(stack, 1, 9), []int{1, 9, 2, 3}},
+		{"remove", withoutEntry(stack, 1), []int{1, 3}},
+		{"swap", swapped(stack, 0, 2), []int{3, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+	if !reflect.DeepEqual(stack, []int{1, 2, 3}) {
+		t.Errorf("the stack changed to %v", stack)
+	}
+}
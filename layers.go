@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// layer is one of the layers of a course, in the order of the toolbar
+// tabs, which is also front to back.
+type layer int
+
+const (
+	layerArt00 layer = iota
+	layerArt0
+	layerBlocks
+	layerArt1
+	layerArt2
+	layerArt3
+	layerBG
+	numLayers
+)
+
+var layerNames = [numLayers]string{"Art00", "Art0", "Blocks", "Art1", "Art2", "Art3", "BG"}
+
+// art returns the art layer that l is, if it is one.
+func (l layer) art() (level.ArtLayer, bool) {
+	switch l {
+	case layerArt00:
+		return level.Art00, true
+	case layerArt0:
+		return level.Art0, true
+	case layerArt1:
+		return level.Art1, true
+	case layerArt2:
+		return level.Art2, true
+	case layerArt3:
+		return level.Art3, true
+	}
+	return 0, false
+}
+
+// layerState is how a layer is shown and whether it can be edited.
+type layerState struct {
+	hidden, locked bool
+	opacity        float32
+}
+
+func defaultLayers() [numLayers]layerState {
+	var layers [numLayers]layerState
+	for i := range layers {
+		layers[i].opacity = 1
+	}
+	return layers
+}
+
+// alpha is how opaque l is drawn, 0 when hidden.
+func (e *Editor) alpha(l layer) float64 {
+	if e.layers[l].hidden {
+		return 0
+	}
+	return float64(e.layers[l].opacity)
+}
+
+// locked reports whether the tools must leave l alone.
+func (e *Editor) locked(l layer) bool {
+	return e.readOnly || e.layers[l].locked
+}
+
+// artLayer returns the layer of an art layer.
+func artLayer(al level.ArtLayer) layer {
+	switch al {
+	case level.Art00:
+		return layerArt00
+	case level.Art0:
+		return layerArt0
+	case level.Art1:
+		return layerArt1
+	case level.Art2:
+		return layerArt2
+	}
+	return layerArt3
+}
+
+func (e *Editor) layersWindow() {
+	if imgui.Begin("Layers") {
+		imgui.ColumnsV(4, "layers", false)
+		for i := range e.layers {
+			l := &e.layers[i]
+			imgui.PushID(layerNames[i])
+			name := layerNames[i]
+			if layer(i) == e.layer {
+				name = fmt.Sprintf("> %s", name)
+			}
+			imgui.Text(name)
+			imgui.NextColumn()
+			visible := !l.hidden
+			if imgui.Checkbox("Show", &visible) {
+				l.hidden = !visible
+			}
+			imgui.NextColumn()
+			imgui.Checkbox("Lock", &l.locked)
+			imgui.NextColumn()
+			imgui.PushItemWidth(-1)
+			imgui.SliderFloat("##opacity", &l.opacity, 0, 1)
+			imgui.PopItemWidth()
+			imgui.NextColumn()
+			imgui.PopID()
+		}
+		imgui.Columns()
+	}
+	imgui.End()
+}
+
+// fade blends c over black, as a layer drawn with alpha over nothing.
+func fade(c color.Color, alpha float64) color.Color {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA64{
+		R: uint16(float64(r) * alpha),
+		G: uint16(float64(g) * alpha),
+		B: uint16(float64(b) * alpha),
+		A: 0xffff,
+	}
+}
@@ -0,0 +1,267 @@
+package level
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ArtLayer is one of the layers of art drawn around the blocks.
+type ArtLayer int
+
+const (
+	Art00 ArtLayer = iota
+	Art0
+	Art1
+	Art2
+	Art3
+	NumArtLayers
+)
+
+// ArtLayers names the art layers.
+var ArtLayers = [NumArtLayers]string{"Art00", "Art0", "Art1", "Art2", "Art3"}
+
+// Parallax is how fast each art layer scrolls relative to the blocks.
+var Parallax = [NumArtLayers]float64{
+	Art00: 1,
+	Art0:  1,
+	Art1:  1,
+	Art2:  0.5,
+	Art3:  0.25,
+}
+
+// Front reports whether the layer is drawn in front of the blocks.
+func (l ArtLayer) Front() bool {
+	return l == Art00 || l == Art0
+}
+
+// Art is what is drawn on an art layer. Stamps are drawn first, then the
+// lines, so that erasing only removes lines.
+type Art struct {
+	Stamps []Stamp
+	Lines  []Line
+}
+
+// Stamp is a placed art object.
+type Stamp struct {
+	X, Y int
+	ID   int
+}
+
+// Line is a freehand stroke through Points, in layer pixels.
+type Line struct {
+	Color     color.RGBA
+	Thickness int
+	// Erase makes the line remove the lines under it instead of drawing.
+	Erase  bool
+	Points []image.Point
+}
+
+// Clone returns a copy of a that shares nothing with it, and compares
+// equal to it with Compare.
+func (a Art) Clone() Art {
+	b := Art{Stamps: append([]Stamp(nil), a.Stamps...)}
+	for _, l := range a.Lines {
+		l.Points = append([]image.Point(nil), l.Points...)
+		b.Lines = append(b.Lines, l)
+	}
+	return b
+}
+
+// Empty reports whether nothing is drawn.
+func (a Art) Empty() bool {
+	return len(a.Stamps) == 0 && len(a.Lines) == 0
+}
+
+// The data field of a level is split by backticks into segments, and the
+// art of each layer is stored in these two of them.
+var artSegments = [NumArtLayers]struct{ stamps, lines int }{
+	Art00: {11, 13},
+	Art0:  {10, 12},
+	Art1:  {3, 6},
+	Art2:  {4, 7},
+	Art3:  {5, 8},
+}
+
+// parseArt reads the art out of the data field of a level.
+func parseArt(data string) [NumArtLayers]Art {
+	var art [NumArtLayers]Art
+	seg := strings.Split(data, "`")
+	get := func(i int) string {
+		if i < len(seg) {
+			return seg[i]
+		}
+		return ""
+	}
+	for l, s := range artSegments {
+		art[l].Stamps = decodeStamps(get(s.stamps))
+		art[l].Lines = decodeLines(get(s.lines))
+	}
+	return art
+}
+
+// setArt stores art into the data field of an upload form, and updates
+// the hash of the form if the data changed.
+func setArt(val url.Values, art [NumArtLayers]Art, user string) {
+	data := val.Get("data")
+	seg := strings.Split(data, "`")
+	for l, s := range artSegments {
+		stamps, lines := encodeStamps(art[l].Stamps), encodeLines(art[l].Lines)
+		if stamps == "" && lines == "" && s.lines >= len(seg) {
+			continue
+		}
+		for len(seg) <= s.lines || len(seg) <= s.stamps {
+			seg = append(seg, "")
+		}
+		seg[s.stamps], seg[s.lines] = stamps, lines
+	}
+	if newData := strings.Join(seg, "`"); newData != data {
+		val.Set("data", newData)
+		if val.Get("hash") != "" {
+			val.Set("hash", levelHash(val.Get("title"), user, newData))
+		}
+	}
+}
+
+// hashSalt is what the game client salts the hash of uploads with.
+const hashSalt = "84ge5tnr"
+
+func levelHash(title, user, data string) string {
+	sum := md5.Sum([]byte(title + strings.ToLower(user) + data + hashSalt))
+	return hex.EncodeToString(sum[:])
+}
+
+// Stamps are separated by commas as "x;y;id", where the position is
+// relative to the previous stamp.
+func encodeStamps(stamps []Stamp) string {
+	var b strings.Builder
+	var x, y int
+	for i, s := range stamps {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%d;%d;%d", s.X-x, s.Y-y, s.ID)
+		x, y = s.X, s.Y
+	}
+	return b.String()
+}
+
+func decodeStamps(s string) []Stamp {
+	var stamps []Stamp
+	var x, y int
+	for _, field := range strings.Split(s, ",") {
+		n, ok := ints(field)
+		if !ok || len(n) < 3 {
+			continue
+		}
+		x, y = x+n[0], y+n[1]
+		stamps = append(stamps, Stamp{X: x, Y: y, ID: n[2]})
+	}
+	return stamps
+}
+
+// Lines are a comma separated list of commands: "c" followed by a hex
+// color, "t" and a thickness, "md" to draw or "me" to erase, then "d" and
+// the points of a line separated by semicolons. The first point is
+// absolute and the others are relative to the point before. The color,
+// thickness and mode apply to the lines that follow them.
+func encodeLines(lines []Line) string {
+	var cmds []string
+	var (
+		clr   color.RGBA
+		thick int
+		erase bool
+	)
+	first := true
+	for _, l := range lines {
+		if len(l.Points) == 0 {
+			continue
+		}
+		if first || l.Color != clr {
+			clr = l.Color
+			cmds = append(cmds, fmt.Sprintf("c%02x%02x%02x", clr.R, clr.G, clr.B))
+		}
+		if first || l.Thickness != thick {
+			thick = l.Thickness
+			cmds = append(cmds, "t"+strconv.Itoa(thick))
+		}
+		if first || l.Erase != erase {
+			erase = l.Erase
+			if erase {
+				cmds = append(cmds, "me")
+			} else {
+				cmds = append(cmds, "md")
+			}
+		}
+		first = false
+		var b strings.Builder
+		b.WriteByte('d')
+		prev := image.Point{}
+		for j, p := range l.Points {
+			if j > 0 {
+				b.WriteByte(';')
+			}
+			d := p.Sub(prev)
+			fmt.Fprintf(&b, "%d;%d", d.X, d.Y)
+			prev = p
+		}
+		cmds = append(cmds, b.String())
+	}
+	return strings.Join(cmds, ",")
+}
+
+func decodeLines(s string) []Line {
+	var lines []Line
+	cur := Line{Color: color.RGBA{A: 0xff}, Thickness: 4}
+	for _, cmd := range strings.Split(s, ",") {
+		if cmd == "" {
+			continue
+		}
+		arg := cmd[1:]
+		switch cmd[0] {
+		case 'c':
+			if n, err := strconv.ParseUint(arg, 16, 32); err == nil {
+				cur.Color = color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xff}
+			}
+		case 't':
+			if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+				cur.Thickness = n
+			}
+		case 'm':
+			cur.Erase = arg == "e"
+		case 'd':
+			n, ok := ints(arg)
+			if !ok || len(n) < 2 {
+				continue
+			}
+			l := cur
+			l.Points = make([]image.Point, 0, len(n)/2)
+			p := image.Point{}
+			for i := 0; i+1 < len(n); i += 2 {
+				p = p.Add(image.Pt(n[i], n[i+1]))
+				l.Points = append(l.Points, p)
+			}
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// ints parses numbers separated by semicolons.
+func ints(s string) ([]int, bool) {
+	fields := strings.Split(s, ";")
+	n := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, false
+		}
+		n[i] = v
+	}
+	return n, true
+}
@@ -0,0 +1,45 @@
+package level
+
+import (
+	"image"
+	"image/color"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestArtRoundTrip(t *testing.T) {
+	var art [NumArtLayers]Art
+	art[Art1] = Art{
+		Stamps: []Stamp{{X: 10, Y: -20, ID: 3}, {X: 5, Y: 40, ID: 0}},
+		Lines: []Line{
+			{Color: color.RGBA{0xff, 0x80, 0, 0xff}, Thickness: 4, Points: []image.Point{{0, 0}, {10, 5}, {-3, 7}}},
+			{Color: color.RGBA{0xff, 0x80, 0, 0xff}, Thickness: 9, Erase: true, Points: []image.Point{{1, 1}}},
+		},
+	}
+	art[Art00] = Art{Lines: []Line{
+		{Color: color.RGBA{A: 0xff}, Thickness: 1, Points: []image.Point{{100, 200}, {101, 200}}},
+	}}
+
+	val := url.Values{"data": {"m4`ffffff`0;0;1"}}
+	setArt(val, art, "alice")
+	got := parseArt(val.Get("data"))
+	if !reflect.DeepEqual(got, art) {
+		t.Errorf("parseArt() = %+v, want %+v", got, art)
+	}
+	if seg := val.Get("data")[:len("m4`ffffff`0;0;1")]; seg != "m4`ffffff`0;0;1" {
+		t.Errorf("setArt() changed the blocks: %q", seg)
+	}
+}
+
+func TestSetArtKeepsData(t *testing.T) {
+	// levels without art must upload exactly as the course encodes them
+	val := url.Values{"data": {"m4`ffffff`0;0;1"}, "hash": {"abc"}}
+	setArt(val, [NumArtLayers]Art{}, "alice")
+	if got := val.Get("data"); got != "m4`ffffff`0;0;1" {
+		t.Errorf("data = %q", got)
+	}
+	if got := val.Get("hash"); got != "abc" {
+		t.Errorf("hash = %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package level
+
+import (
+	"image/color"
+	"reflect"
+
+	"github.com/fourst4r/course"
+)
+
+// Diff is what changed from one level to another.
+type Diff struct {
+	// Added, Removed and Changed are the cells whose stacks were placed,
+	// emptied or changed, in reading order.
+	Added, Removed, Changed []course.XY
+	// Fields names what else changed, like "title" or "art".
+	Fields []string
+}
+
+// Empty reports whether the levels are the same.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Fields) == 0
+}
+
+// Compare returns what changed from a to b.
+func Compare(a, b *Level) Diff {
+	var d Diff
+	d.Added, d.Removed, d.Changed = diffStacks(stacks(a.Course), stacks(b.Course))
+	field := func(name string, x, y interface{}) {
+		if !reflect.DeepEqual(x, y) {
+			d.Fields = append(d.Fields, name)
+		}
+	}
+	field("title", a.Title, b.Title)
+	field("note", a.Note, b.Note)
+	field("published", a.Live, b.Live)
+	if !sameColor(a.BackgroundColor, b.BackgroundColor) {
+		d.Fields = append(d.Fields, "background")
+	}
+	field("settings", a.Settings, b.Settings)
+	field("art", a.Art, b.Art)
+	return d
+}
+
+// sameColor reports whether a and b look the same, even if they are of
+// different color types.
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
+func diffStacks(a, b map[course.XY][]int) (added, removed, changed []course.XY) {
+	for xy, stack := range b {
+		old, ok := a[xy]
+		switch {
+		case !ok || len(old) == 0:
+			if len(stack) > 0 {
+				added = append(added, xy)
+			}
+		case len(stack) == 0:
+			removed = append(removed, xy)
+		case !reflect.DeepEqual(old, stack):
+			changed = append(changed, xy)
+		}
+	}
+	for xy, stack := range a {
+		if _, ok := b[xy]; !ok && len(stack) > 0 {
+			removed = append(removed, xy)
+		}
+	}
+	sortCells(added)
+	sortCells(removed)
+	sortCells(changed)
+	return added, removed, changed
+}
@@ -0,0 +1,66 @@
+package level
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/fourst4r/course"
+)
+
+func Test_diffStacks(t *testing.T) {
+	a := map[course.XY][]int{
+		at(0, 0): {1},
+		at(1, 0): {2, 3},
+		at(2, 0): {4},
+		at(3, 0): {5},
+		at(5, 0): {},
+	}
+	b := map[course.XY][]int{
+		at(0, 0): {1},
+		at(1, 0): {3, 2},
+		at(3, 0): {},
+		at(4, 1): {6},
+		at(4, 0): {7},
+		at(5, 0): {8},
+	}
+	added, removed, changed := diffStacks(a, b)
+	tests := []struct {
+		name      string
+		got, want []course.XY
+	}{
+		{"added", added, []course.XY{at(4, 0), at(5, 0), at(4, 1)}},
+		{"removed", removed, []course.XY{at(2, 0), at(3, 0)}},
+		{"changed", changed, []course.XY{at(1, 0)}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestCompareBackground(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	tests := []struct {
+		name string
+		a, b color.Color
+		want bool
+	}{
+		{"same", red, red, false},
+		{"another type", red, color.NRGBA{R: 255, A: 255}, false},
+		{"other color", red, color.RGBA{G: 255, A: 255}, true},
+		{"no colors", nil, nil, false},
+		{"one color", nil, red, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Level{Course: &course.Course{BackgroundColor: tt.a}}
+			b := &Level{Course: &course.Course{BackgroundColor: tt.b}}
+			d := Compare(a, b)
+			if got := len(d.Fields) > 0; got != tt.want {
+				t.Errorf("Compare() fields = %v, want background changed %v", d.Fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package level holds the course helpers that don't need a window, so that
+// they can be shared by the editor and the headless command line tool.
+package level
+
+import (
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+
+	"github.com/fourst4r/course"
+)
+
+// Blocks names the block IDs, in the order of the tiles in pr2-blocks.png.
+var Blocks = []string{
+	"Basic1", "Basic2", "Basic3", "Basic4", "Brick", "Down", "Up", "Left", "Right", "Mine",
+	"Item", "Player1", "Player2", "Player3", "Player4", "Ice", "Finish", "Crumble", "Vanish", "Move",
+	"Water", "Rotate Right", "Rotate Left", "Push", "Net", "Item+", "Happy", "Sad", "Heart", "Time",
+	"Egg",
+}
+
+// TileSize is the size of a block in world pixels.
+const TileSize = 30
+
+// BlockID strips the offset of 100 that some levels add to block IDs.
+func BlockID(id int) int {
+	if id > 99 {
+		id -= 100
+	}
+	return id
+}
+
+// BlockName returns the name of the block id, or its number if unknown.
+func BlockName(id int) string {
+	id = BlockID(id)
+	if id >= 0 && id < len(Blocks) {
+		return Blocks[id]
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// Level is a course together with what course.Course doesn't keep, like
+// the music, the items and the art.
+type Level struct {
+	*course.Course
+	Settings
+	Art [NumArtLayers]Art
+}
+
+// New returns the default course with the default settings.
+func New() *Level {
+	return &Level{Course: course.Default(), Settings: DefaultSettings()}
+}
+
+// Parse parses a level in the text format that pr2hub serves.
+func Parse(data string) (*Level, error) {
+	c, err := course.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	val := rawValues(data)
+	return &Level{
+		Course:   c,
+		Settings: parseSettings(val),
+		Art:      parseArt(val["data"]),
+	}, nil
+}
+
+// Values returns the upload form of the level.
+func (l *Level) Values(user string) url.Values {
+	val := l.Course.Values(user)
+	l.Settings.Set(val)
+	setArt(val, l.Art, user)
+	return val
+}
+
+// Encode serializes l in the text format that Parse reads, which is the
+// upload form as pr2hub stores it.
+func Encode(l *Level, user string) string {
+	return l.Values(user).Encode()
+}
+
+// Load reads and parses a level file, or stdin if path is "-".
+func Load(path string) (*Level, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(b))
+}
+
+// Info summarizes a course.
+type Info struct {
+	Title      string         `json:"title"`
+	Note       string         `json:"note"`
+	Live       bool           `json:"live"`
+	Background string         `json:"background"`
+	Blocks     int            `json:"blocks"`
+	Cells      int            `json:"cells"`
+	Counts     map[string]int `json:"counts"`
+	// Bounds of the blocks in block units, inclusive.
+	MinX int `json:"min_x"`
+	MinY int `json:"min_y"`
+	MaxX int `json:"max_x"`
+	MaxY int `json:"max_y"`
+}
+
+func Describe(c *course.Course) Info {
+	info := Info{
+		Title:  c.Title,
+		Note:   c.Note,
+		Live:   c.Live,
+		Counts: make(map[string]int),
+	}
+	if c.BackgroundColor != nil {
+		r, g, b, _ := color.RGBAModel.Convert(c.BackgroundColor).RGBA()
+		info.Background = fmt.Sprintf("%02x%02x%02x", r>>8, g>>8, b>>8)
+	}
+	info.MinX, info.MinY = math.MaxInt32, math.MaxInt32
+	info.MaxX, info.MaxY = math.MinInt32, math.MinInt32
+	for xy, stack := range c.Blocks {
+		if len(stack) == 0 {
+			continue
+		}
+		info.Cells++
+		for _, block := range stack {
+			info.Blocks++
+			info.Counts[BlockName(block.(int))]++
+		}
+		x, y := cell(xy.X), cell(xy.Y)
+		info.MinX, info.MaxX = min(info.MinX, x), max(info.MaxX, x)
+		info.MinY, info.MaxY = min(info.MinY, y), max(info.MaxY, y)
+	}
+	if info.Cells == 0 {
+		info.MinX, info.MinY, info.MaxX, info.MaxY = 0, 0, 0, 0
+	}
+	return info
+}
+
+func cell(v int) int {
+	return int(math.Floor(float64(v) / TileSize))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
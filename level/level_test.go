@@ -0,0 +1,31 @@
+package level
+
+import (
+	"image"
+	"testing"
+)
+
+func TestBlockName(t *testing.T) {
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{0, "Basic1"},
+		{11, "Player1"},
+		{116, "Finish"},
+		{99, "#99"},
+		{-1, "#-1"},
+	}
+	for _, tt := range tests {
+		if got := BlockName(tt.id); got != tt.want {
+			t.Errorf("BlockName(%d) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestTileRect(t *testing.T) {
+	got := TileRect(116) // Finish, offset by 100
+	if want := image.Rect(180, 30, 210, 60); got != want {
+		t.Errorf("TileRect(116) = %v, want %v", got, want)
+	}
+}
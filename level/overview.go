@@ -0,0 +1,161 @@
+package level
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/fourst4r/course"
+)
+
+// TileColors returns the average color of each block in the atlas, for
+// drawing courses with a pixel or less per block.
+func TileColors(atlas image.Image) []color.RGBA {
+	colors := make([]color.RGBA, len(Blocks))
+	for id := range colors {
+		var r, g, b, a uint64
+		if atlas != nil {
+			rect := TileRect(id).Intersect(atlas.Bounds())
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					cr, cg, cb, ca := atlas.At(x, y).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+				}
+			}
+		}
+		if a == 0 {
+			colors[id] = color.RGBA{0x80, 0x80, 0x80, 0xff}
+			continue
+		}
+		// the colors are premultiplied, so dividing by the alpha leaves
+		// out the transparent pixels
+		colors[id] = color.RGBA{uint8(r * 0xff / a), uint8(g * 0xff / a), uint8(b * 0xff / a), 0xff}
+	}
+	return colors
+}
+
+// bucket adds up the colors of the blocks that a pixel stands for.
+type bucket struct {
+	r, g, b, n int
+}
+
+// Overview is a map of a course small enough to show whole, where each
+// pixel is a square of blocks in the average color of their top blocks.
+// It is updated a cell at a time rather than redrawn.
+type Overview struct {
+	Img *image.RGBA
+	// Bounds are the blocks it can show, in block units. The top left
+	// pixel is the square of blocks at Bounds.Min.
+	Bounds image.Rectangle
+	// Scale is how many blocks wide and high a pixel is.
+	Scale int
+
+	colors  []color.RGBA
+	bg      color.RGBA
+	top     map[image.Point]int
+	buckets []bucket
+}
+
+// NewOverview returns an empty overview of the blocks in bounds, scaled
+// down to at most max pixels either way.
+func NewOverview(bounds image.Rectangle, max int, colors []color.RGBA, bg color.Color) *Overview {
+	side := bounds.Dx()
+	if bounds.Dy() > side {
+		side = bounds.Dy()
+	}
+	scale := (side + max - 1) / max
+	if scale < 1 {
+		scale = 1
+	}
+	w, h := (bounds.Dx()+scale-1)/scale, (bounds.Dy()+scale-1)/scale
+	o := &Overview{
+		Img:     image.NewRGBA(image.Rect(0, 0, w, h)),
+		Bounds:  bounds,
+		Scale:   scale,
+		colors:  colors,
+		top:     make(map[image.Point]int),
+		buckets: make([]bucket, w*h),
+	}
+	o.SetBackground(bg)
+	return o
+}
+
+// OverviewOf draws c with a margin of a block around the edges, like
+// Render, but at most max pixels either way.
+func OverviewOf(c *course.Course, max int, colors []color.RGBA) *Overview {
+	info := Describe(c)
+	bounds := image.Rect(info.MinX-1, info.MinY-1, info.MaxX+2, info.MaxY+2)
+	o := NewOverview(bounds, max, colors, c.BackgroundColor)
+	for xy, stack := range c.Blocks {
+		if len(stack) > 0 {
+			o.Set(cell(xy.X), cell(xy.Y), stack[len(stack)-1].(int))
+		}
+	}
+	return o
+}
+
+// SetBackground repaints the pixels without blocks in bg, or white if bg
+// is nil. It reports whether the color changed.
+func (o *Overview) SetBackground(bg color.Color) bool {
+	if bg == nil {
+		bg = color.White
+	}
+	c := color.RGBAModel.Convert(bg).(color.RGBA)
+	c.A = 0xff
+	if c == o.bg {
+		return false
+	}
+	o.bg = c
+	for i := range o.buckets {
+		o.paint(i)
+	}
+	return true
+}
+
+// Set changes the top block of the cell x, y in block units, or empties
+// it if block is negative. It reports false if the cell is out of bounds,
+// which needs a new overview.
+func (o *Overview) Set(x, y, block int) bool {
+	p := image.Pt(x, y)
+	if !p.In(o.Bounds) {
+		return block < 0
+	}
+	old, had := o.top[p]
+	if (had && old == block) || (!had && block < 0) {
+		return true
+	}
+	i := o.bucketOf(p)
+	b := &o.buckets[i]
+	if had {
+		c := o.color(old)
+		b.r, b.g, b.b, b.n = b.r-int(c.R), b.g-int(c.G), b.b-int(c.B), b.n-1
+		delete(o.top, p)
+	}
+	if block >= 0 {
+		c := o.color(block)
+		b.r, b.g, b.b, b.n = b.r+int(c.R), b.g+int(c.G), b.b+int(c.B), b.n+1
+		o.top[p] = block
+	}
+	o.paint(i)
+	return true
+}
+
+func (o *Overview) bucketOf(p image.Point) int {
+	p = p.Sub(o.Bounds.Min).Div(o.Scale)
+	return p.Y*o.Img.Rect.Dx() + p.X
+}
+
+func (o *Overview) color(block int) color.RGBA {
+	if id := BlockID(block); id >= 0 && id < len(o.colors) {
+		return o.colors[id]
+	}
+	return color.RGBA{0x80, 0x80, 0x80, 0xff}
+}
+
+func (o *Overview) paint(i int) {
+	b := o.buckets[i]
+	c := o.bg
+	if b.n > 0 {
+		c = color.RGBA{uint8(b.r / b.n), uint8(b.g / b.n), uint8(b.b / b.n), 0xff}
+	}
+	o.Img.SetRGBA(i%o.Img.Rect.Dx(), i/o.Img.Rect.Dx(), c)
+}
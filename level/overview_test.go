@@ -0,0 +1,61 @@
+package level
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestOverview(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}
+	bg := color.RGBA{0, 0, 0xff, 0xff}
+	o := NewOverview(image.Rect(-1, -1, 9, 5), 4, []color.RGBA{black, white}, bg)
+	if o.Scale != 3 || o.Img.Rect != image.Rect(0, 0, 4, 2) {
+		t.Fatalf("NewOverview() = scale %d, %v, want scale 3, 4x2", o.Scale, o.Img.Rect)
+	}
+
+	steps := []struct {
+		name      string
+		x, y, top int
+		ok        bool
+		px, py    int
+		want      color.RGBA
+	}{
+		{"place", 0, 0, 0, true, 0, 0, black},
+		{"average", 1, 1, 1, true, 0, 0, color.RGBA{0x7f, 0x7f, 0x7f, 0xff}},
+		{"replace", 0, 0, 101, true, 0, 0, white},
+		{"same again", 0, 0, 1, true, 0, 0, white},
+		{"clear", 0, 0, -1, true, 0, 0, white},
+		{"clear last", 1, 1, -1, true, 0, 0, bg},
+		{"clear empty", 5, 3, -1, true, 2, 1, bg},
+		{"last pixel", 8, 4, 0, true, 3, 1, black},
+		{"out of bounds", 9, 0, 0, false, 3, 0, bg},
+		{"clear out of bounds", -2, 0, -1, true, 0, 0, bg},
+	}
+	for _, s := range steps {
+		if ok := o.Set(s.x, s.y, s.top); ok != s.ok {
+			t.Errorf("%s: Set() = %v, want %v", s.name, ok, s.ok)
+		}
+		if got := o.Img.RGBAAt(s.px, s.py); got != s.want {
+			t.Errorf("%s: pixel %d,%d = %v, want %v", s.name, s.px, s.py, got, s.want)
+		}
+	}
+
+	if !o.SetBackground(white) || o.SetBackground(white) {
+		t.Error("SetBackground() should only report a new color")
+	}
+	if got := o.Img.RGBAAt(0, 0); got != white {
+		t.Errorf("empty pixel after SetBackground = %v, want %v", got, white)
+	}
+	if got := o.Img.RGBAAt(3, 1); got != black {
+		t.Errorf("pixel with a block after SetBackground = %v, want %v", got, black)
+	}
+}
+
+func TestNewOverviewSpread(t *testing.T) {
+	// a stray block far from the rest must not make a huge image
+	o := NewOverview(image.Rect(-1, -1, 1000000, 3), 200, nil, nil)
+	if w, h := o.Img.Rect.Dx(), o.Img.Rect.Dy(); w > 200 || h > 200 {
+		t.Errorf("overview is %dx%d, want at most 200x200", w, h)
+	}
+}
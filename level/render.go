@@ -0,0 +1,111 @@
+package level
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/fourst4r/course"
+	xdraw "golang.org/x/image/draw"
+)
+
+// TileColumns is the number of tiles in a row of the block atlas.
+const TileColumns = 10
+
+// DefaultAtlas is where the editor keeps the block atlas.
+const DefaultAtlas = "assets/pr2-blocks.png"
+
+// LoadAtlas reads the block atlas image.
+func LoadAtlas(path string) (image.Image, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	return img, err
+}
+
+// TileRect returns where the block id is in the atlas.
+func TileRect(id int) image.Rectangle {
+	id = BlockID(id)
+	sx := (id % TileColumns) * TileSize
+	sy := (id / TileColumns) * TileSize
+	return image.Rect(sx, sy, sx+TileSize, sy+TileSize)
+}
+
+const (
+	// MaxRenderSize is the widest or tallest image Render makes.
+	MaxRenderSize = 16384
+	// MaxRenderPixels caps the memory of an image from Render at 256MB.
+	MaxRenderPixels = 1 << 26
+)
+
+// renderTile returns the size of a block in an image rendered at scale,
+// which is never less than a pixel.
+func renderTile(scale float64) int {
+	return int(math.Max(1, math.Round(TileSize*scale)))
+}
+
+// renderSize returns the size of the image that Render makes of a course
+// described by info, or an error if it is too large to make.
+func renderSize(info Info, scale float64) (image.Point, error) {
+	tile := renderTile(scale)
+	w, h := (info.MaxX-info.MinX+3)*tile, (info.MaxY-info.MinY+3)*tile
+	if w > MaxRenderSize || h > MaxRenderSize || w*h > MaxRenderPixels {
+		return image.Point{}, fmt.Errorf("level: a %dx%d image is too large to render, the blocks are too far apart for scale %g", w, h, scale)
+	}
+	return image.Pt(w, h), nil
+}
+
+// Render draws every block of c over its background color, scaled by
+// scale, with a margin of one block around the edges. It fails rather
+// than make an image over MaxRenderSize or MaxRenderPixels.
+func Render(c *course.Course, atlas image.Image, scale float64) (*image.RGBA, error) {
+	info := Describe(c)
+	size, err := renderSize(info, scale)
+	if err != nil {
+		return nil, err
+	}
+	x0, y0 := (info.MinX-1)*TileSize, (info.MinY-1)*TileSize
+	tile := renderTile(scale)
+	at := func(x, y int) image.Point {
+		return image.Pt((x-x0)*tile/TileSize, (y-y0)*tile/TileSize)
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	var bg color.Color = color.White
+	if c.BackgroundColor != nil {
+		bg = c.BackgroundColor
+	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	for xy, stack := range c.Blocks {
+		p := at(xy.X, xy.Y)
+		dst := image.Rectangle{Min: p, Max: p.Add(image.Pt(tile, tile))}
+		for _, block := range stack {
+			src := TileRect(block.(int))
+			if tile == TileSize {
+				draw.Draw(img, dst, atlas, src.Min, draw.Over)
+			} else {
+				xdraw.ApproxBiLinear.Scale(img, dst, atlas, src, draw.Over, nil)
+			}
+		}
+	}
+	return img, nil
+}
+
+// WritePNG renders c as a PNG image to w. Nothing is written if c is too
+// large to render.
+func WritePNG(w io.Writer, c *course.Course, atlas image.Image, scale float64) error {
+	img, err := Render(c, atlas, scale)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
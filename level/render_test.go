@@ -0,0 +1,31 @@
+package level
+
+import (
+	"image"
+	"testing"
+)
+
+func Test_renderSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  Info
+		scale float64
+		want  image.Point
+		ok    bool
+	}{
+		{"one block", Info{}, 1, image.Pt(90, 90), true},
+		{"half size", Info{MaxX: 9, MaxY: 1}, 0.5, image.Pt(180, 60), true},
+		{"tiny scale", Info{MaxX: 9, MaxY: 1}, 0.001, image.Pt(12, 4), true},
+		{"stray block", Info{MinX: -5, MaxX: 100000, MaxY: 3}, 1, image.Point{}, false},
+		{"stray block at a pixel per block", Info{MinX: -5, MaxX: 100000, MaxY: 3}, 1.0 / TileSize, image.Point{}, false},
+		{"too many pixels", Info{MaxX: 500, MaxY: 500}, 1, image.Point{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderSize(tt.info, tt.scale)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("renderSize() = %v, %v, want %v, ok %v", got, err, tt.want, tt.ok)
+			}
+		})
+	}
+}
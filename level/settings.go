@@ -0,0 +1,141 @@
+package level
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Items names the items in the order the editor lists them.
+var Items = []string{
+	"Laser Gun", "Speed Burst", "Jet Pack", "Super Jump", "Lightning",
+	"Sword", "Teleport", "Mine", "Ice Wave",
+}
+
+// itemIDs are the hub's IDs of Items.
+var itemIDs = []int{1, 7, 6, 5, 3, 8, 4, 2, 9}
+
+// Modes are the game modes, by the names the hub uses.
+var Modes = []string{"race", "deathmatch", "objective", "egg"}
+
+// Settings are the options of a level besides its blocks and art.
+type Settings struct {
+	// Song is the index of the music, 0 for none.
+	Song int
+	// Items are the items that item blocks give, in the order of Items.
+	Items   [9]bool
+	MinRank int
+	Gravity float64
+	// Time is the time limit in seconds, or 0 for none.
+	Time int
+	// Mode is one of Modes.
+	Mode string
+	// CowboyChance is the percent chance of getting a cowboy hat.
+	CowboyChance int
+	// Pass is the password to play the level, or empty for none.
+	Pass string
+	// HasPass is set for levels from the hub that have a password. The hub
+	// never sends the password back, so it must be entered again to keep
+	// it when uploading.
+	HasPass bool
+}
+
+// DefaultSettings returns the settings of a new level.
+func DefaultSettings() Settings {
+	s := Settings{
+		Gravity:      1,
+		Time:         120,
+		Mode:         "race",
+		CowboyChance: 5,
+	}
+	for i := range s.Items {
+		s.Items[i] = true
+	}
+	return s
+}
+
+// ParseSettings reads the settings from a level in the text format that
+// pr2hub serves. Missing or malformed settings keep their defaults.
+func ParseSettings(data string) Settings {
+	return parseSettings(rawValues(data))
+}
+
+func parseSettings(val map[string]string) Settings {
+	s := DefaultSettings()
+	atoi := func(key string, v *int) {
+		if n, err := strconv.Atoi(val[key]); err == nil {
+			*v = n
+		}
+	}
+	atoi("song", &s.Song)
+	atoi("min_level", &s.MinRank)
+	atoi("max_time", &s.Time)
+	atoi("cowboyChance", &s.CowboyChance)
+	if g, err := strconv.ParseFloat(val["gravity"], 64); err == nil {
+		s.Gravity = g
+	}
+	if mode, ok := val["gameMode"]; ok && mode != "" {
+		s.Mode = mode
+	}
+	if items, ok := val["items"]; ok {
+		s.Items = [9]bool{}
+		for _, item := range strings.Split(items, "`") {
+			id, err := strconv.Atoi(item)
+			if err != nil {
+				continue
+			}
+			for i, itemID := range itemIDs {
+				if id == itemID {
+					s.Items[i] = true
+				}
+			}
+		}
+	}
+	s.Pass = val["password"]
+	s.HasPass = val["has_pass"] == "1" || s.Pass != ""
+	return s
+}
+
+// Set stores the settings into an upload form.
+func (s Settings) Set(val url.Values) {
+	var items []string
+	for i, ok := range s.Items {
+		if ok {
+			items = append(items, strconv.Itoa(itemIDs[i]))
+		}
+	}
+	val.Set("song", strconv.Itoa(s.Song))
+	val.Set("items", strings.Join(items, "`"))
+	val.Set("min_level", strconv.Itoa(s.MinRank))
+	val.Set("gravity", strconv.FormatFloat(s.Gravity, 'f', -1, 64))
+	val.Set("max_time", strconv.Itoa(s.Time))
+	val.Set("gameMode", s.Mode)
+	val.Set("cowboyChance", strconv.Itoa(s.CowboyChance))
+	if s.Pass != "" {
+		val.Set("has_pass", "1")
+		val.Set("password", s.Pass)
+	} else {
+		val.Set("has_pass", "0")
+		val.Del("password")
+	}
+}
+
+// rawValues splits a level into its fields. Levels are not always escaped
+// correctly, so fields that fail to unescape are kept as they are.
+func rawValues(data string) map[string]string {
+	val := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimSpace(data), "&") {
+		i := strings.IndexByte(field, '=')
+		if i == -1 {
+			continue
+		}
+		key, v := field[:i], field[i+1:]
+		if u, err := url.QueryUnescape(v); err == nil {
+			v = u
+		}
+		if _, ok := val[key]; !ok {
+			val[key] = v
+		}
+	}
+	return val
+}
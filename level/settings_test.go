@@ -0,0 +1,51 @@
+package level
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSettingsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Settings
+	}{
+		{"default", DefaultSettings()},
+		{"custom", Settings{
+			Song:         2,
+			Items:        [9]bool{true, false, true, false, false, false, false, true, true},
+			MinRank:      30,
+			Gravity:      1.5,
+			Time:         0,
+			Mode:         "egg",
+			CowboyChance: 100,
+			Pass:         "open sesame",
+			HasPass:      true,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val := make(url.Values)
+			tt.s.Set(val)
+			if got := ParseSettings(val.Encode()); got != tt.s {
+				t.Errorf("ParseSettings() = %+v, want %+v", got, tt.s)
+			}
+		})
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	// levels from the hub are not always escaped correctly
+	data := "title=100%&song=1&items=1`9&gravity=2.0&max_time=60&has_pass=1&gameMode=deathmatch&data=m4`a&b"
+	s := ParseSettings(data)
+	want := DefaultSettings()
+	want.Song = 1
+	want.Items = [9]bool{0: true, 8: true}
+	want.Gravity = 2
+	want.Time = 60
+	want.HasPass = true
+	want.Mode = "deathmatch"
+	if s != want {
+		t.Errorf("ParseSettings() = %+v, want %+v", s, want)
+	}
+}
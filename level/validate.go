@@ -0,0 +1,166 @@
+package level
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fourst4r/course"
+)
+
+// BlockFinish is the ID of the Finish block.
+const BlockFinish = 16
+
+// WorldLimit is how many blocks from the origin a block can be before it
+// is reported as out of bounds.
+const WorldLimit = 2000
+
+// Severity tells errors, which break a level, from warnings.
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "error":
+		*s = Error
+	case "warning":
+		*s = Warning
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+	return nil
+}
+
+// Diagnostic is a problem found by Validate.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Located is set for problems at a cell, which is At in world pixels
+	// and X, Y in block units.
+	Located bool      `json:"located"`
+	At      course.XY `json:"-"`
+	X       int       `json:"x"`
+	Y       int       `json:"y"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Located {
+		return fmt.Sprintf("%s: %d,%d: %s", d.Severity, d.X, d.Y, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+}
+
+// Errors counts the diagnostics that are errors.
+func Errors(diags []Diagnostic) int {
+	n := 0
+	for _, d := range diags {
+		if d.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+// Validate checks l for the mistakes that make levels unplayable, with
+// the problems at a cell sorted by position.
+func Validate(l *Level) []Diagnostic {
+	return validate(l.Title, l.Mode, stacks(l.Course))
+}
+
+// stacks returns the block IDs of each cell of c, bottom first.
+func stacks(c *course.Course) map[course.XY][]int {
+	m := make(map[course.XY][]int, len(c.Blocks))
+	for xy, stack := range c.Blocks {
+		for _, block := range stack {
+			m[xy] = append(m[xy], block.(int))
+		}
+	}
+	return m
+}
+
+// sortCells sorts cells in reading order.
+func sortCells(cells []course.XY) {
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+}
+
+func validate(title, mode string, stacks map[course.XY][]int) []Diagnostic {
+	var diags []Diagnostic
+	report := func(sev Severity, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{Severity: sev, Message: fmt.Sprintf(format, args...)})
+	}
+	at := func(xy course.XY, sev Severity, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{
+			Severity: sev,
+			Message:  fmt.Sprintf(format, args...),
+			Located:  true,
+			At:       xy,
+			X:        cell(xy.X),
+			Y:        cell(xy.Y),
+		})
+	}
+
+	if title == "" {
+		report(Error, "the level has no title")
+	}
+
+	cells := make([]course.XY, 0, len(stacks))
+	for xy := range stacks {
+		cells = append(cells, xy)
+	}
+	sortCells(cells)
+
+	players := make(map[int][]course.XY)
+	finish := false
+	for _, xy := range cells {
+		x, y := cell(xy.X), cell(xy.Y)
+		if x < -WorldLimit || x > WorldLimit || y < -WorldLimit || y > WorldLimit {
+			at(xy, Warning, "block is more than %d blocks from the start", WorldLimit)
+		}
+		for _, block := range stacks[xy] {
+			id := BlockID(block)
+			switch {
+			case id < 0 || id >= len(Blocks):
+				at(xy, Error, "unknown block ID %d", block)
+			case id >= course.BlockPlayer1 && id <= course.BlockPlayer4:
+				players[id] = append(players[id], xy)
+			case id == BlockFinish:
+				finish = true
+			}
+		}
+	}
+
+	for id := course.BlockPlayer1; id <= course.BlockPlayer4; id++ {
+		switch xys := players[id]; len(xys) {
+		case 0:
+			report(Error, "there is no %s start", Blocks[id])
+		case 1:
+		default:
+			for _, xy := range xys[1:] {
+				at(xy, Error, "%s start is placed %d times", Blocks[id], len(xys))
+			}
+		}
+	}
+	if mode == "race" && !finish {
+		report(Error, "a race needs a Finish block")
+	}
+	return diags
+}
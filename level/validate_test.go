@@ -0,0 +1,78 @@
+package level
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fourst4r/course"
+)
+
+func at(x, y int) course.XY {
+	return course.XY{X: x * TileSize, Y: y * TileSize}
+}
+
+func TestValidate(t *testing.T) {
+	players := func() map[course.XY][]int {
+		return map[course.XY][]int{
+			at(0, 0): {course.BlockPlayer1},
+			at(1, 0): {course.BlockPlayer2},
+			at(2, 0): {course.BlockPlayer3 + 100},
+			at(3, 0): {course.BlockPlayer4},
+			at(4, 0): {BlockFinish},
+		}
+	}
+	tests := []struct {
+		name   string
+		title  string
+		mode   string
+		change func(map[course.XY][]int)
+		want   []string
+	}{
+		{"ok", "a", "race", func(map[course.XY][]int) {}, nil},
+		{"no title", "", "race", func(map[course.XY][]int) {}, []string{
+			"error: the level has no title",
+		}},
+		{"missing player", "a", "race", func(m map[course.XY][]int) {
+			delete(m, at(1, 0))
+		}, []string{
+			"error: there is no Player2 start",
+		}},
+		{"duplicate player", "a", "race", func(m map[course.XY][]int) {
+			m[at(0, 5)] = []int{0, course.BlockPlayer1}
+		}, []string{
+			"error: 0,5: Player1 start is placed 2 times",
+		}},
+		{"no finish", "a", "race", func(m map[course.XY][]int) {
+			delete(m, at(4, 0))
+		}, []string{
+			"error: a race needs a Finish block",
+		}},
+		{"no finish outside a race", "a", "deathmatch", func(m map[course.XY][]int) {
+			delete(m, at(4, 0))
+		}, nil},
+		{"far away", "a", "race", func(m map[course.XY][]int) {
+			m[at(-WorldLimit-1, 3)] = []int{0}
+		}, []string{
+			"warning: -2001,3: block is more than 2000 blocks from the start",
+		}},
+		{"unknown block", "a", "race", func(m map[course.XY][]int) {
+			m[at(7, 7)] = []int{0, 99, 150}
+		}, []string{
+			"error: 7,7: unknown block ID 99",
+			"error: 7,7: unknown block ID 150",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := players()
+			tt.change(m)
+			var got []string
+			for _, d := range validate(tt.title, tt.mode, m) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
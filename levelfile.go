@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// levelExt is added to saved files that were named without an extension.
+const levelExt = ".txt"
+
+func (e *Editor) user() string {
+	if sel := e.config.selectedAcc(); sel != -1 {
+		return e.config.Accs[sel].User
+	}
+	return ""
+}
+
+// savePath returns the file that saving to path writes.
+func savePath(path string) string {
+	if filepath.Ext(path) == "" {
+		path += levelExt
+	}
+	return path
+}
+
+// overwrites reports whether saving to path would replace a file other
+// than the current one.
+func (e *Editor) overwrites(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	if e.path == "" {
+		return true
+	}
+	cur, err1 := filepath.Abs(e.path)
+	abs, err2 := filepath.Abs(path)
+	return err1 != nil || err2 != nil || cur != abs
+}
+
+// saveFile writes the course to path and makes it the current file.
+func (e *Editor) saveFile(path string) error {
+	path = savePath(path)
+	data := level.Encode(e.current(), e.user())
+	if err := writeFileAtomic(path, []byte(data), 0644); err != nil {
+		return err
+	}
+	log.Println("Saved level to", path)
+	e.endStroke()
+	e.path = path
+	e.history.markSaved()
+	e.indexSaved(path)
+	return nil
+}
+
+func (e *Editor) dirty() bool {
+	return e.history.dirty() || (e.stroke != nil && !e.stroke.empty())
+}
+
+// updateTitle shows the current file and whether it has unsaved changes
+// in the window title.
+func (e *Editor) updateTitle() {
+	name := "untitled"
+	if e.path != "" {
+		name = filepath.Base(e.path)
+	}
+	if e.dirty() {
+		name += "*"
+	}
+	if e.readOnly {
+		name += " (read-only)"
+	}
+	title := fmt.Sprintf("%s - %s v%s", name, AppName, AppVersion)
+	if title != e.title {
+		e.title = title
+		ebiten.SetWindowTitle(title)
+	}
+}
+
+// save saves to the current file, or asks for one if there is none.
+func (e *Editor) save() {
+	if e.path == "" {
+		e.openSaveAs = true
+		return
+	}
+	e.saveTo(e.path)
+}
+
+const (
+	PopupSaveAs          = "Save As##PopupSaveAs"
+	PopupSaveAsFailure   = "Save failed##PopupSaveAsFailure"
+	PopupSaveAsOverwrite = "Replace file##PopupSaveAsOverwrite"
+)
+
+// saveTo saves to path and shows what went wrong.
+func (e *Editor) saveTo(path string) {
+	if err := e.saveFile(path); err != nil {
+		e.fileerr = err.Error()
+		log.Println(err)
+		e.openSaveFailure = true
+	}
+}
+
+func (e *Editor) saveAsPopup() {
+	if e.openSaveAs {
+		e.openSaveAs = false
+		dir, name := filepath.Split(e.path)
+		if e.path == "" {
+			name = e.Course.Title
+		}
+		e.files.open(dir)
+		e.files.name = name
+		imgui.OpenPopup(PopupSaveAs)
+	}
+	if e.openSaveFailure {
+		e.openSaveFailure = false
+		imgui.OpenPopup(PopupSaveAsFailure)
+	}
+	// PopupSaveAs
+	imgui.SetNextWindowSize(imgui.Vec2{X: 500, Y: 0})
+	if imgui.BeginPopupModalV(PopupSaveAs, nil, imgui.WindowFlagsNone) {
+		chosen := e.files.draw()
+		if (imgui.Button("Save") || chosen) && e.files.name != "" {
+			if path := savePath(e.files.path()); e.overwrites(path) {
+				e.overwrite = path
+				defer imgui.OpenPopup(PopupSaveAsOverwrite)
+			} else {
+				e.saveTo(path)
+			}
+			imgui.CloseCurrentPopup()
+		}
+		imgui.SameLine()
+		if imgui.Button("Cancel") {
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+	// PopupSaveAsOverwrite
+	msg := fmt.Sprintf("%s already exists. Do you want to replace it?", filepath.Base(e.overwrite))
+	if open, yes := yesnoPopup(PopupSaveAsOverwrite, msg); open && yes {
+		e.saveTo(e.overwrite)
+	}
+	// PopupSaveAsFailure
+	if imgui.BeginPopupModalV(PopupSaveAsFailure, nil, imgui.WindowFlagsAlwaysAutoResize) {
+		imgui.Text(e.fileerr)
+		if imgui.Button("OK") {
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditorOverwrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "levedit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cur, other := filepath.Join(dir, "cur.txt"), filepath.Join(dir, "other.txt")
+	for _, p := range []string{cur, other} {
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name, path, save string
+		want             bool
+	}{
+		{"new file", cur, filepath.Join(dir, "new.txt"), false},
+		{"current file", cur, cur, false},
+		{"current file by another path", cur, filepath.Join(dir, ".", "cur.txt"), false},
+		{"other file", cur, other, true},
+		{"unsaved course", "", other, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Editor{path: tt.path}
+			if got := e.overwrites(tt.save); got != tt.want {
+				t.Errorf("overwrites(%q) = %v, want %v", tt.save, got, tt.want)
+			}
+		})
+	}
+	if got, want := savePath(filepath.Join(dir, "cur")), cur; got != want {
+		t.Errorf("savePath() = %q, want %q", got, want)
+	}
+}
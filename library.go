@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fourst4r/levedit/level"
+)
+
+const (
+	// libraryIndex is the file in the library directory that describes
+	// the levels in it.
+	libraryIndex = "index.json"
+	// thumbDir holds the thumbnails, inside the library directory.
+	thumbDir = ".thumbs"
+	// thumbSize is the most pixels a thumbnail is wide or high.
+	thumbSize = 200
+)
+
+// libraryEntry describes a level file in the library.
+type libraryEntry struct {
+	// File is the name of the level in the library directory.
+	File   string
+	Title  string
+	Note   string   `json:",omitempty"`
+	Tags   []string `json:",omitempty"`
+	Blocks int
+	Counts map[string]int `json:",omitempty"`
+	// Thumb is the name of the thumbnail in thumbDir, if there is one.
+	Thumb string `json:",omitempty"`
+	// LevelID and Version are the level on the hub the file was saved
+	// from. Levels made locally have no LevelID.
+	LevelID string `json:",omitempty"`
+	Version int    `json:",omitempty"`
+	// Edited is when the file was last written.
+	Edited time.Time
+}
+
+// local reports whether the level was never on the hub.
+func (en *libraryEntry) local() bool {
+	return en.LevelID == ""
+}
+
+// originLabel describes where the level came from.
+func (en *libraryEntry) originLabel() string {
+	if en.local() {
+		return "local"
+	}
+	return "#" + en.LevelID + " v" + strconv.Itoa(en.Version)
+}
+
+// matches reports whether every word is in the title, note or file name
+// and every tag is on the level. Both are lower case.
+func (en *libraryEntry) matches(words, tags []string) bool {
+	text := strings.ToLower(en.Title + "\n" + en.Note + "\n" + en.File)
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	for _, t := range tags {
+		if !hasTag(en.Tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// library is a directory of level files and an index of what they hold,
+// so they can be found without opening each one.
+type library struct {
+	dir string
+	// entries are sorted by when they were edited, newest first
+	entries []*libraryEntry
+	// colors draw the thumbnails, or none if nil
+	colors []color.RGBA
+}
+
+// openLibrary opens the library in dir, creating it if needed, and brings
+// the index up to date with the files in it.
+func openLibrary(dir string, atlas image.Image) (*library, error) {
+	if err := os.MkdirAll(filepath.Join(dir, thumbDir), 0755); err != nil {
+		return nil, err
+	}
+	lib := &library{dir: dir}
+	if atlas != nil {
+		lib.colors = level.TileColors(atlas)
+	}
+	if err := lib.readIndex(); err != nil {
+		// the index only saves opening the files, so start over
+		log.Println("Rebuilding the library index:", err)
+		lib.entries = nil
+	}
+	if err := lib.sync(); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+func (lib *library) path(file string) string {
+	return filepath.Join(lib.dir, file)
+}
+
+func (lib *library) readIndex() error {
+	b, err := ioutil.ReadFile(lib.path(libraryIndex))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &lib.entries)
+}
+
+// save writes the index. The lock keeps other editors from saving at the
+// same time.
+func (lib *library) save() error {
+	path := lib.path(libraryIndex)
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b, err := json.MarshalIndent(lib.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(b, '\n'), 0644)
+}
+
+// entry returns the entry of file, or nil.
+func (lib *library) entry(file string) *libraryEntry {
+	for _, en := range lib.entries {
+		if en.File == file {
+			return en
+		}
+	}
+	return nil
+}
+
+// contains reports whether path is a level file in the library, and
+// returns its name there.
+func (lib *library) contains(path string) (file string, ok bool) {
+	return inLibrary(lib.dir, path)
+}
+
+// inLibrary reports whether path is a level file in the library in dir,
+// without opening the library.
+func inLibrary(dir, path string) (file string, ok bool) {
+	fileDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return "", false
+	}
+	libDir, err := filepath.Abs(dir)
+	if err != nil || fileDir != libDir {
+		return "", false
+	}
+	return filepath.Base(path), filepath.Ext(path) == levelExt
+}
+
+// sync reads the level files that changed since they were indexed, and
+// forgets the ones that are gone.
+func (lib *library) sync() error {
+	infos, err := ioutil.ReadDir(lib.dir)
+	if err != nil {
+		return err
+	}
+	changed := false
+	seen := make(map[string]bool)
+	for _, fi := range infos {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != levelExt {
+			continue
+		}
+		seen[fi.Name()] = true
+		en := lib.entry(fi.Name())
+		if en != nil && en.Edited.Equal(fi.ModTime()) {
+			continue
+		}
+		l, err := level.Load(lib.path(fi.Name()))
+		if err != nil {
+			// keep what is known about it until it can be read again
+			log.Println(err)
+			continue
+		}
+		if en == nil {
+			en = &libraryEntry{File: fi.Name()}
+			lib.entries = append(lib.entries, en)
+		}
+		lib.describe(en, l, fi.ModTime())
+		changed = true
+	}
+	entries := lib.entries[:0]
+	for _, en := range lib.entries {
+		if seen[en.File] {
+			entries = append(entries, en)
+			continue
+		}
+		lib.removeThumb(en)
+		changed = true
+	}
+	lib.entries = entries
+	if !changed {
+		return nil
+	}
+	lib.sort()
+	return lib.save()
+}
+
+// describe fills in what en says about the level l, and draws its
+// thumbnail.
+func (lib *library) describe(en *libraryEntry, l *level.Level, edited time.Time) {
+	info := level.Describe(l.Course)
+	en.Title, en.Note = info.Title, info.Note
+	en.Blocks, en.Counts = info.Blocks, info.Counts
+	en.Edited = edited
+	if lib.colors == nil {
+		return
+	}
+	img := level.OverviewOf(l.Course, thumbSize, lib.colors).Img
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Println(err)
+		return
+	}
+	thumb := strings.TrimSuffix(en.File, levelExt) + ".png"
+	if err := writeFileAtomic(filepath.Join(lib.dir, thumbDir, thumb), buf.Bytes(), 0644); err != nil {
+		log.Println(err)
+		return
+	}
+	en.Thumb = thumb
+}
+
+func (lib *library) removeThumb(en *libraryEntry) {
+	if en.Thumb == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(lib.dir, thumbDir, en.Thumb)); err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+}
+
+// thumb reads the thumbnail of en.
+func (lib *library) thumb(en *libraryEntry) (image.Image, error) {
+	f, err := os.Open(filepath.Join(lib.dir, thumbDir, en.Thumb))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func (lib *library) sort() {
+	sort.SliceStable(lib.entries, func(i, j int) bool {
+		return lib.entries[i].Edited.After(lib.entries[j].Edited)
+	})
+}
+
+// update indexes the level l that was just saved as file in the library,
+// downloaded from the hub as o.
+func (lib *library) update(file string, l *level.Level, o origin) error {
+	fi, err := os.Stat(lib.path(file))
+	if err != nil {
+		return err
+	}
+	en := lib.entry(file)
+	if en == nil {
+		en = &libraryEntry{File: file}
+		lib.entries = append(lib.entries, en)
+	}
+	lib.describe(en, l, fi.ModTime())
+	if o.levelID != "" {
+		en.LevelID, en.Version = o.levelID, o.version
+	}
+	lib.sort()
+	return lib.save()
+}
+
+// setTags replaces the tags of en.
+func (lib *library) setTags(en *libraryEntry, tags []string) error {
+	en.Tags = tags
+	return lib.save()
+}
+
+// search returns the levels that match query, newest first. Words that
+// start with # are tags, the others must be in the title, note or file
+// name.
+func (lib *library) search(query string) []*libraryEntry {
+	words, tags := parseQuery(query)
+	var found []*libraryEntry
+	for _, en := range lib.entries {
+		if en.matches(words, tags) {
+			found = append(found, en)
+		}
+	}
+	return found
+}
+
+// parseQuery splits a search into lower case words and tags.
+func parseQuery(query string) (words, tags []string) {
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		if strings.HasPrefix(w, "#") {
+			if t := strings.TrimPrefix(w, "#"); t != "" {
+				tags = append(tags, t)
+			}
+			continue
+		}
+		words = append(words, w)
+	}
+	return words, tags
+}
+
+// parseTags reads tags separated by commas or spaces, without the #,
+// lower case, sorted and once each.
+func parseTags(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	var tags []string
+	for _, f := range fields {
+		t := strings.TrimLeft(f, "#")
+		if t != "" && !hasTag(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// libraryFile returns a file name for a level titled title that isn't
+// taken in the library yet.
+func (lib *library) libraryFile(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			return r
+		case unicode.IsSpace(r):
+			return '_'
+		}
+		return -1
+	}, title)
+	if name == "" {
+		name = "untitled"
+	}
+	file := name + levelExt
+	for i := 2; ; i++ {
+		if _, err := os.Stat(lib.path(file)); os.IsNotExist(err) && lib.entry(file) == nil {
+			return file
+		}
+		file = name + "_" + strconv.Itoa(i) + levelExt
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+)
+
+func tempLibrary(t *testing.T) (lib *library, done func()) {
+	dir, err := ioutil.TempDir("", "levedit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib, err = openLibrary(dir, image.NewRGBA(image.Rect(0, 0, level.TileSize, level.TileSize)))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return lib, func() { os.RemoveAll(dir) }
+}
+
+// shelve saves a level titled title as file in lib, edited at.
+func shelve(t *testing.T, lib *library, file, title string, at time.Time, o origin) {
+	path := lib.path(file)
+	if err := ioutil.WriteFile(path, []byte("title="+title), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+	l := &level.Level{Course: &course.Course{Title: title}}
+	if err := lib.update(file, l, o); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func files(entries []*libraryEntry) []string {
+	var f []string
+	for _, en := range entries {
+		f = append(f, en.File)
+	}
+	return f
+}
+
+func TestLibraryIndex(t *testing.T) {
+	lib, done := tempLibrary(t)
+	defer done()
+	now := time.Now()
+	shelve(t, lib, "old.txt", "Old", now.Add(-time.Hour), origin{levelID: "42", version: 3, latest: 5})
+	shelve(t, lib, "new.txt", "New", now, origin{})
+	if err := lib.setTags(lib.entry("new.txt"), []string{"race"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the files didn't change, so the index is used as saved
+	lib, err := openLibrary(lib.dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := files(lib.entries), []string{"new.txt", "old.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+	old, fresh := lib.entry("old.txt"), lib.entry("new.txt")
+	if old.Title != "Old" || old.LevelID != "42" || old.Version != 3 || old.local() {
+		t.Errorf("old = %+v, want level 42 version 3", old)
+	}
+	if !fresh.local() || !reflect.DeepEqual(fresh.Tags, []string{"race"}) {
+		t.Errorf("new = %+v, want local and tagged race", fresh)
+	}
+	if img, err := lib.thumb(old); err != nil {
+		t.Errorf("thumb(old): %v", err)
+	} else if size := img.Bounds().Size(); size.X > thumbSize || size.Y > thumbSize {
+		t.Errorf("thumb(old) is %v, want at most %d pixels either way", size, thumbSize)
+	}
+
+	// a file deleted behind its back is dropped with its thumbnail
+	if err := os.Remove(lib.path("old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := files(lib.entries), []string{"new.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries after removing = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(lib.dir, thumbDir, old.Thumb)); !os.IsNotExist(err) {
+		t.Errorf("the thumbnail of old.txt is still there: %v", err)
+	}
+}
+
+func TestLibraryCorruptIndex(t *testing.T) {
+	lib, done := tempLibrary(t)
+	defer done()
+	if err := ioutil.WriteFile(lib.path(libraryIndex), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := openLibrary(lib.dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lib.entries) != 0 {
+		t.Errorf("entries = %v, want none", files(lib.entries))
+	}
+}
+
+func TestLibrarySearch(t *testing.T) {
+	lib := &library{entries: []*libraryEntry{
+		{File: "race_1.txt", Title: "Speed Race", Tags: []string{"race", "wip"}},
+		{File: "maze.txt", Title: "The Maze", Note: "A long race through a maze", Tags: []string{"maze"}},
+		{File: "hat.txt", Title: "Hat Attack", Tags: []string{"wip"}},
+	}}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"race_1.txt", "maze.txt", "hat.txt"}},
+		{"race", []string{"race_1.txt", "maze.txt"}},
+		{"RACE maze", []string{"maze.txt"}},
+		{"#wip", []string{"race_1.txt", "hat.txt"}},
+		{"#wip #race", []string{"race_1.txt"}},
+		{"race #wip", []string{"race_1.txt"}},
+		{"hat.txt", []string{"hat.txt"}},
+		{"#ra", nil},
+		{"nothing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := files(lib.search(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseTags(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"race", []string{"race"}},
+		{"WIP, race,,maze", []string{"maze", "race", "wip"}},
+		{"#race race  #", []string{"race"}},
+	}
+	for _, tt := range tests {
+		if got := parseTags(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTags(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_libraryFile(t *testing.T) {
+	lib, done := tempLibrary(t)
+	defer done()
+	shelve(t, lib, "My_Level.txt", "My Level", time.Now(), origin{})
+	tests := []struct {
+		title, want string
+	}{
+		{"Other", "Other.txt"},
+		{"My Level", "My_Level_2.txt"},
+		{"../../etc/passwd", "etcpasswd.txt"},
+		{"???", "untitled.txt"},
+	}
+	for _, tt := range tests {
+		if got := lib.libraryFile(tt.title); got != tt.want {
+			t.Errorf("libraryFile(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func Test_inLibrary(t *testing.T) {
+	dir := filepath.Join("levels", "library")
+	tests := []struct {
+		path, file string
+		ok         bool
+	}{
+		{filepath.Join(dir, "race.txt"), "race.txt", true},
+		{filepath.Join(dir, ".", "race.txt"), "race.txt", true},
+		{filepath.Join(dir, "notes.md"), "notes.md", false},
+		{filepath.Join(dir, thumbDir, "race.txt"), "", false},
+		{filepath.Join("levels", "race.txt"), "", false},
+	}
+	for _, tt := range tests {
+		file, ok := inLibrary(dir, tt.path)
+		if ok != tt.ok || (ok && file != tt.file) {
+			t.Errorf("inLibrary(%q) = %q, %v, want %q, %v", tt.path, file, ok, tt.file, tt.ok)
+		}
+	}
+}
+
+func Test_blockCounts(t *testing.T) {
+	got := blockCounts(map[string]int{"Basic1": 3, "Mine": 5, "Brick": 3})
+	if want := "Mine 5, Basic1 3, Brick 3"; got != want {
+		t.Errorf("blockCounts() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// thumbTexture is the imgui texture ID of the selected library thumbnail.
+const thumbTexture = imgui.TextureID(1002)
+
+// shelf is the state of the library tab of the load dialog.
+type shelf struct {
+	query string
+	// selected is the file name of the selected level
+	selected string
+	tags     string
+	thumb    *ebiten.Image
+	thumbOf  string
+	status   string
+}
+
+// lib returns the library, opening it the first time.
+func (e *Editor) lib() *library {
+	if e.library == nil {
+		lib, err := openLibrary(e.config.libraryDir(), blocksAtlas)
+		if err != nil {
+			log.Println(err)
+			e.shelf.status = err.Error()
+			return nil
+		}
+		e.library = lib
+	}
+	return e.library
+}
+
+// indexSaved keeps the library index up to date with a level saved into
+// the library directory. The library is only opened for those.
+func (e *Editor) indexSaved(path string) {
+	file, ok := inLibrary(e.config.libraryDir(), path)
+	if !ok {
+		return
+	}
+	if lib := e.lib(); lib != nil {
+		if err := lib.update(file, e.current(), e.origin); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// addToLibrary writes the course into the library as file, leaving the file
+// being edited as it is.
+func (e *Editor) addToLibrary(lib *library, file string) error {
+	data := level.Encode(e.current(), e.user())
+	if err := writeFileAtomic(lib.path(file), []byte(data), 0644); err != nil {
+		return err
+	}
+	log.Println("Added the course to the library as", file)
+	return lib.update(file, e.current(), e.origin)
+}
+
+// selectShelved selects the level of en and starts editing its tags.
+func (e *Editor) selectShelved(en *libraryEntry) {
+	e.shelf.selected = en.File
+	e.shelf.tags = strings.Join(en.Tags, ", ")
+}
+
+func (e *Editor) libraryTab() {
+	lib, s := e.lib(), &e.shelf
+	if lib == nil {
+		imgui.Text(s.status)
+		return
+	}
+	imgui.InputText("Search", &s.query)
+	imgui.SameLine()
+	if imgui.Button("Rescan") {
+		if err := lib.sync(); err != nil {
+			log.Println(err)
+			s.status = err.Error()
+		}
+	}
+	imgui.Text("Words are looked for in the title, note and file name, #words in the tags.")
+	found := lib.search(s.query)
+
+	imgui.ColumnsV(2, "library", true)
+	imgui.BeginChildV("shelved", imgui.Vec2{X: 0, Y: 300}, false, 0)
+	for _, en := range found {
+		label := fmt.Sprintf("%s (%s)##%s", en.Title, en.originLabel(), en.File)
+		if imgui.SelectableV(label, en.File == s.selected, 0, imgui.Vec2{}) {
+			e.selectShelved(en)
+		}
+	}
+	if len(found) == 0 {
+		imgui.Text("No levels found")
+	}
+	imgui.EndChild()
+	imgui.NextColumn()
+
+	en := lib.entry(s.selected)
+	if en != nil {
+		e.shelvedDetails(lib, en)
+	}
+	imgui.Columns()
+	imgui.Separator()
+
+	if s.status != "" {
+		imgui.Text(s.status)
+	}
+	if en != nil {
+		if imgui.Button("Open##library") {
+			if err := e.openShelved(lib, en); err != nil {
+				log.Println(err)
+				s.status = err.Error()
+			} else {
+				imgui.CloseCurrentPopup()
+			}
+		}
+		imgui.SameLine()
+	}
+	if imgui.Button("Add the course") {
+		file, ok := lib.contains(e.path)
+		if !ok {
+			file = lib.libraryFile(e.Course.Title)
+		}
+		if err := e.addToLibrary(lib, file); err != nil {
+			log.Println(err)
+			s.status = err.Error()
+		} else if en := lib.entry(file); en != nil {
+			e.selectShelved(en)
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Close##library") {
+		imgui.CloseCurrentPopup()
+	}
+}
+
+// shelvedDetails shows the thumbnail and index of en, and edits its tags.
+func (e *Editor) shelvedDetails(lib *library, en *libraryEntry) {
+	s := &e.shelf
+	if en.Thumb != "" && s.thumbOf != en.File {
+		e.loadThumb(lib, en)
+	}
+	if s.thumb != nil && s.thumbOf == en.File {
+		w, h := s.thumb.Size()
+		scale := fitScale(w, h)
+		imgui.Image(thumbTexture, imgui.Vec2{X: float32(float64(w) * scale), Y: float32(float64(h) * scale)})
+	}
+	imgui.Text(en.Title)
+	imgui.Text(fmt.Sprintf("%s, from %s", en.File, en.originLabel()))
+	imgui.Text("Edited " + en.Edited.Format("2006-01-02 15:04"))
+	imgui.Text(fmt.Sprintf("%d blocks", en.Blocks))
+	imgui.PushTextWrapPos()
+	imgui.Text(blockCounts(en.Counts))
+	imgui.Text(en.Note)
+	imgui.PopTextWrapPos()
+	imgui.InputText("Tags", &s.tags)
+	imgui.SameLine()
+	if imgui.Button("Set") {
+		if err := lib.setTags(en, parseTags(s.tags)); err != nil {
+			log.Println(err)
+			s.status = err.Error()
+		}
+		s.tags = strings.Join(en.Tags, ", ")
+	}
+}
+
+func (e *Editor) loadThumb(lib *library, en *libraryEntry) {
+	s := &e.shelf
+	s.thumbOf = en.File
+	img, err := lib.thumb(en)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	tex, err := ebiten.NewImageFromImage(img, ebiten.FilterNearest)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if s.thumb != nil {
+		s.thumb.Dispose()
+	}
+	s.thumb = tex
+	e.mgr.Cache.SetTexture(thumbTexture, tex)
+}
+
+// openShelved opens the level of en, still tied to the level on the hub it
+// was saved from.
+func (e *Editor) openShelved(lib *library, en *libraryEntry) error {
+	if err := e.loadFile(lib.path(en.File)); err != nil {
+		return err
+	}
+	if !en.local() {
+		e.versions.reset()
+		e.origin = origin{levelID: en.LevelID, version: en.Version, latest: en.Version}
+	}
+	return nil
+}
+
+// blockCounts lists how many of each block there are, most first.
+func blockCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,19 +1,21 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	_ "image/png"
-	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
-	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"time"
 
 	"github.com/inkyblackness/imgui-go/v2"
 
+	"github.com/fourst4r/levedit/level"
 	"github.com/fourst4r/levedit/pr2hub"
 
 	"github.com/fourst4r/course"
@@ -25,35 +27,27 @@ import (
 )
 
 var (
-	blocks = []string{
-		// "Basic1", "Basic2", "Basic3", "Basic4", "Brick", "Finish", "Ice", "Item",
-		// "Item+", "Left", "Right", "Up", "Down", "Mine", "Crumble", "Vanish", "Move",
-		// "Water", "Rotate Right", "Rotate Left", "Push", "Happy", "Sad", "Net", "Heart",
-		// "Time", "Egg",
-		"Basic1", "Basic2", "Basic3", "Basic4", "Brick", "Down", "Up", "Left", "Right", "Mine",
-		"Item", "Player1", "Player2", "Player3", "Player4", "Ice", "Finish", "Crumble", "Vanish", "Move",
-		"Water", "Rotate Right", "Rotate Left", "Push", "Net", "Item+", "Happy", "Sad", "Heart", "Time",
-		"Egg",
-	}
-	songs = []string{
+	blocks = level.Blocks
+	songs  = []string{
 		"None", "Random", "Orbital Trance - Space Planet",
 	}
 )
 
 var (
+	blocksAtlas image.Image
 	blocksImage *ebiten.Image
 	blockImgs   map[int]*ebiten.Image
+	// blockColors draw the blocks in maps too small for their tiles
+	blockColors []color.RGBA
 )
 
 func init() {
-	b, err := ioutil.ReadFile("assets/pr2-blocks.png")
-	if err != nil {
-		log.Fatal(err)
-	}
-	img, _, err := image.Decode(bytes.NewReader(b))
+	img, err := level.LoadAtlas(level.DefaultAtlas)
 	if err != nil {
 		log.Fatal(err)
 	}
+	blocksAtlas = img
+	blockColors = level.TileColors(img)
 	blocksImage, _ = ebiten.NewImageFromImage(img, ebiten.FilterDefault)
 	blockImgs = make(map[int]*ebiten.Image)
 	for i := 0; i < len(blocks); i++ {
@@ -77,19 +71,33 @@ type Editor struct {
 	block course.Block
 	// bg
 	backgroundColor [3]float32
+	// layers
+	layer  layer
+	layers [numLayers]layerState
+	// art
+	art          [level.NumArtLayers]level.Art
+	artTool      artTool
+	artColor     [3]float32
+	artThickness int32
+	stampID      int32
+	// line is the line being drawn while the mouse button is held
+	line      *level.Line
+	lineLayer level.ArtLayer
+	artCanvas *ebiten.Image
 	// settings
-	song         int
-	items        [9]bool
-	minRank      int
-	gravity      float64
-	time         int
-	mode         string
-	cowboyChance int
-	pass         string
+	opts level.Settings
 	// login
 	loginuser, loginpass string
 	loginremember        bool
 	loginstatus          string
+	openLogin            bool
+	// sessionChecks are the saved logins being checked with the hub
+	sessionChecks []*sessionCheck
+	// unlock
+	openUnlock bool
+	// loginAfterUnlock reopens the login popup once the logins are unlocked
+	loginAfterUnlock         bool
+	unlockpass, unlockstatus string
 	// loginresp            *pr2hub.LoginResponse
 	// load
 	levelsgetresp  pr2hub.LevelsGetResponse
@@ -99,6 +107,25 @@ type Editor struct {
 	// download level
 	dldone  bool
 	dllevel string
+	dlinfo  pr2hub.LevelInfo
+	// load file
+	files   fileBrowser
+	fileerr string
+	// save file
+	path, title     string
+	openSaveAs      bool
+	openSaveFailure bool
+	// overwrite is the existing file that Save As asks to replace
+	overwrite string
+	// export
+	openExport  bool
+	exportScale float32
+	// autosave
+	autosavedAt time.Time
+	autosaved   int
+	journaled   bool
+	restore     *journal
+	openRestore bool
 	// goto
 	gotoX, gotoY int32
 	// save
@@ -106,8 +133,49 @@ type Editor struct {
 	// delete
 	deleteresp pr2hub.DeleteLevelResponse
 
-	req    *pr2hub.Req
+	hub    *pr2hub.Client
 	config *Config
+	// requests to the hub in flight, one per popup so they don't cancel
+	// or consume each other's responses
+	loginReq  *pr2hub.LoginReq
+	levelsReq *pr2hub.LevelsGetReq
+	levelReq  *pr2hub.LevelReq
+	saveReq   *pr2hub.UploadLevelReq
+	deleteReq *pr2hub.DeleteLevelReq
+	// sessionReq checks the login before uploading
+	sessionReq *pr2hub.CheckLoginReq
+
+	history history
+	// stroke collects the blocks painted while a mouse button is held
+	stroke *blocksEdit
+
+	tool  tool
+	shape shape
+	// hover is the cell under the mouse cursor
+	hover     course.XY
+	inspector inspector
+	// diagnostics
+	diagnostics     []level.Diagnostic
+	showDiagnostics bool
+	sel             selection
+	clipboard       clipboard
+	clipboardRect   rect
+	minimap         minimap
+	showGrid        bool
+	browser         browser
+	// readOnly stops any edits to a level opened from the browser
+	readOnly bool
+	// origin is where on the hub the course came from, if anywhere
+	origin       origin
+	versions     versions
+	showVersions bool
+	// library is opened the first time it's needed
+	library *library
+	shelf   shelf
+	// index finds the blocks on screen
+	index *blockIndex
+	// indexed is the course the index was built from
+	indexed *course.Course
 }
 
 const (
@@ -126,20 +194,74 @@ func (e *Editor) Update(screen *ebiten.Image) error {
 			// g.mgr.ClipMask = !g.mgr.ClipMask
 		}
 
+		if ebiten.IsKeyPressed(ebiten.KeyControl) {
+			switch {
+			case inpututil.IsKeyJustPressed(ebiten.KeyZ):
+				if ebiten.IsKeyPressed(ebiten.KeyShift) {
+					e.redo()
+				} else {
+					e.undo()
+				}
+			case inpututil.IsKeyJustPressed(ebiten.KeyY):
+				e.redo()
+			case inpututil.IsKeyJustPressed(ebiten.KeyC):
+				e.copySelection()
+			case inpututil.IsKeyJustPressed(ebiten.KeyX):
+				e.cutSelection()
+			case inpututil.IsKeyJustPressed(ebiten.KeyV):
+				if xy, ok := e.cursorCell(screen); ok {
+					e.paste(xy)
+				}
+			case inpututil.IsKeyJustPressed(ebiten.KeyS):
+				if ebiten.IsKeyPressed(ebiten.KeyShift) {
+					e.openSaveAs = true
+				} else {
+					e.save()
+				}
+			}
+		} else {
+			switch {
+			case inpututil.IsKeyJustPressed(ebiten.KeyB):
+				e.tool = toolPaint
+			case inpututil.IsKeyJustPressed(ebiten.KeyM):
+				e.tool = toolSelect
+			case inpututil.IsKeyJustPressed(ebiten.KeyG):
+				e.tool = toolFill
+			case inpututil.IsKeyJustPressed(ebiten.KeyL):
+				e.tool = toolLine
+			case inpututil.IsKeyJustPressed(ebiten.KeyU):
+				if ebiten.IsKeyPressed(ebiten.KeyShift) {
+					e.tool = toolFilledRect
+				} else {
+					e.tool = toolRect
+				}
+			case inpututil.IsKeyJustPressed(ebiten.KeyH):
+				e.showGrid = !e.showGrid
+			case inpututil.IsKeyJustPressed(ebiten.KeyDelete):
+				e.deleteSelection()
+			case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+				e.deselect()
+				e.shape.active = false
+			}
+		}
+
 		speed := camSpeed
 		if ebiten.IsKeyPressed(ebiten.KeyShift) {
 			speed *= 4
 		}
 
-		if ebiten.IsKeyPressed(ebiten.KeyLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
-			e.cam.Translate(speed, 0)
-		} else if ebiten.IsKeyPressed(ebiten.KeyRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
-			e.cam.Translate(-speed, 0)
-		}
-		if ebiten.IsKeyPressed(ebiten.KeyUp) || ebiten.IsKeyPressed(ebiten.KeyW) {
-			e.cam.Translate(0, speed)
-		} else if ebiten.IsKeyPressed(ebiten.KeyDown) || ebiten.IsKeyPressed(ebiten.KeyS) {
-			e.cam.Translate(0, -speed)
+		// don't move while using shortcuts like ctrl+s
+		if !ebiten.IsKeyPressed(ebiten.KeyControl) {
+			if ebiten.IsKeyPressed(ebiten.KeyLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
+				e.cam.Translate(speed, 0)
+			} else if ebiten.IsKeyPressed(ebiten.KeyRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
+				e.cam.Translate(-speed, 0)
+			}
+			if ebiten.IsKeyPressed(ebiten.KeyUp) || ebiten.IsKeyPressed(ebiten.KeyW) {
+				e.cam.Translate(0, speed)
+			} else if ebiten.IsKeyPressed(ebiten.KeyDown) || ebiten.IsKeyPressed(ebiten.KeyS) {
+				e.cam.Translate(0, -speed)
+			}
 		}
 	}
 	if !io.WantCaptureMouse() {
@@ -148,43 +270,111 @@ func (e *Editor) Update(screen *ebiten.Image) error {
 		e.zoom *= math.Pow(zoomSpeed, yoff)
 		e.zoom = clamp(e.zoom, zoomMin, zoomMax)
 
-		lmb := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
-		rmb := ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight)
-		if lmb || rmb {
-			g := e.centerCam(screen)
-			if g.IsInvertible() {
-				g.Invert()
-				mx, my := ebiten.CursorPosition()
-				worldx, worldy := g.Apply(float64(mx), float64(my))
-				blockx, blocky := int(math.Floor(worldx/tileSize)), int(math.Floor(worldy/tileSize))
-				gridx, gridy := blockx*tileSize, blocky*tileSize
-
-				if lmb {
-					if _, ok := e.Course.Blocks.Peek(gridx, gridy); !ok {
-						e.Course.Blocks.Push(gridx, gridy, int(e.block))
-					}
-				} else if rmb {
-					e.Course.Blocks.Pop(gridx, gridy)
-				}
-			} else {
-				log.Println("cam is not invertible?", g)
+		if xy, ok := e.cursorCell(screen); ok {
+			e.hover = xy
+			if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) {
+				e.inspector.pinned, e.inspector.cell = true, xy
+			}
+		}
+		if al, ok := e.layer.art(); ok {
+			if !e.locked(e.layer) {
+				e.updateArt(screen, al)
 			}
+		} else if xy, ok := e.cursorCell(screen); ok {
+			switch e.tool {
+			case toolSelect:
+				e.updateSelection(xy)
+			case toolFill, toolLine, toolRect, toolFilledRect:
+				e.updateShape(xy)
+			default:
+				e.updatePaint(xy)
+			}
+		}
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) &&
+		!ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+		e.endStroke()
+		e.endShape()
+		e.endLine()
+		e.endSelectionDrag()
+		if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+			// a color drag in the BG tab ends here
+			e.history.seal()
 		}
 	}
+	e.updateTitle()
+	e.updateAutosave()
 
 	return nil
 }
 
-func (e *Editor) centerCam(screen *ebiten.Image) ebiten.GeoM {
-	bounds := screen.Bounds()
-	var centerX, centerY = float64(bounds.Dx()) / 2, float64(bounds.Dy()) / 2
+func (e *Editor) updatePaint(xy course.XY) {
+	lmb := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	rmb := ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight)
+	if !lmb && !rmb || e.locked(layerBlocks) {
+		return
+	}
+	if e.stroke == nil {
+		e.stroke = newBlocksEdit()
+	}
+	if lmb {
+		if _, ok := e.Course.Blocks.Peek(xy.X, xy.Y); !ok {
+			e.stroke.set(e.Course, xy, []int{int(e.block)})
+		}
+	} else if rmb {
+		if stack := stackAt(e.Course, xy); len(stack) > 0 {
+			e.stroke.set(e.Course, xy, stack[:len(stack)-1])
+		}
+	}
+}
+
+// cursorCell returns the grid cell under the mouse cursor.
+func (e *Editor) cursorCell(screen *ebiten.Image) (course.XY, bool) {
+	mx, my := ebiten.CursorPosition()
+	worldx, worldy := e.screenToWorld(screen, float64(mx), float64(my))
+	if math.IsNaN(worldx) || math.IsNaN(worldy) {
+		return course.XY{}, false
+	}
+	blockx, blocky := int(math.Floor(worldx/tileSize)), int(math.Floor(worldy/tileSize))
+	return course.XY{X: blockx * tileSize, Y: blocky * tileSize}, true
+}
+
+// do applies ed to the course as a single undoable step.
+func (e *Editor) do(ed edit) {
+	e.endStroke()
+	e.history.do(e.Course, ed)
+	e.touched(ed)
+}
 
-	centerCam := ebiten.GeoM{}
-	centerCam.Concat(e.cam)
-	centerCam.Translate(centerX, centerY) // center on screen
-	// centerCam.Translate(tileSize/2, tileSize/2)         // center on block
-	scaleAround(&centerCam, -centerX, -centerY, e.zoom) // zoom around center
-	return centerCam
+// endStroke records the blocks painted since the mouse was pressed.
+func (e *Editor) endStroke() {
+	if e.stroke == nil {
+		return
+	}
+	if !e.stroke.empty() {
+		e.history.push(e.stroke)
+		e.touched(e.stroke)
+	}
+	e.stroke = nil
+}
+
+func (e *Editor) undo() {
+	e.endStroke()
+	ed := e.history.last()
+	if e.history.undo(e.Course) {
+		e.touched(ed)
+	}
+}
+
+func (e *Editor) redo() {
+	e.endStroke()
+	if e.history.redo(e.Course) {
+		e.touched(e.history.last())
+	}
+}
+
+func (e *Editor) centerCam(screen *ebiten.Image) ebiten.GeoM {
+	return e.layerCam(screen, 1)
 }
 
 const (
@@ -195,8 +385,10 @@ const (
 func (e *Editor) Draw(screen *ebiten.Image) {
 	e.mgr.BeginFrame()
 	e.drawUI()
+	e.minimapWindow(screen)
 
-	screen.Fill(e.Course.BackgroundColor)
+	screen.Fill(fade(e.Course.BackgroundColor, e.alpha(layerBG)))
+	e.drawArt(screen, level.Art3, level.Art2, level.Art1)
 
 	// bounds := screen.Bounds()
 	// var centerX, centerY = float64(bounds.Dx()) / 2, float64(bounds.Dy()) / 2
@@ -207,6 +399,9 @@ func (e *Editor) Draw(screen *ebiten.Image) {
 	// centerCam.Translate(-tileSize/2, -tileSize/2)       // center on block
 	// scaleAround(&centerCam, -centerX, -centerY, e.zoom) // zoom around center
 
+	if e.showGrid {
+		e.drawGrid(screen, centerCam)
+	}
 	axisX1, axisY1 := centerCam.Apply(-9999999, 0)
 	axisX2, axisY2 := centerCam.Apply(9999999, 0)
 	ebitenutil.DrawLine(screen, axisX1, axisY1, axisX2, axisY2, colornames.Red)
@@ -215,36 +410,43 @@ func (e *Editor) Draw(screen *ebiten.Image) {
 	axisX2, axisY2 = centerCam.Apply(0, 9999999)
 	ebitenutil.DrawLine(screen, axisX1, axisY1, axisX2, axisY2, colornames.Limegreen)
 
-	for xy, stack := range e.Course.Blocks {
-		for _, block := range stack {
-			bID := block.(int)
-			if bID > 99 {
-				bID -= 100
+	if blocksAlpha := e.alpha(layerBlocks); blocksAlpha > 0 {
+		op := &ebiten.DrawImageOptions{}
+		op.ColorM.Scale(1, 1, 1, blocksAlpha)
+		e.eachVisible(screen, func(xy course.XY) {
+			for _, block := range e.Course.Blocks[xy] {
+				op.GeoM.Reset()
+				op.GeoM.Translate(xytof(xy))
+				op.GeoM.Concat(centerCam)
+				screen.DrawImage(blockImage(block.(int)), op)
 			}
-
-			op := &ebiten.DrawImageOptions{}
-			op.GeoM.Translate(xytof(xy))
-			op.GeoM.Concat(centerCam)
-
-			sx := (bID % tileXNum) * tileSize
-			sy := (bID / tileXNum) * tileSize
-			screen.DrawImage(blocksImage.SubImage(image.Rect(sx, sy, sx+tileSize, sy+tileSize)).(*ebiten.Image), op)
-		}
+		})
 	}
 
+	e.drawArt(screen, level.Art0, level.Art00)
+	e.drawSelection(screen, centerCam)
+	e.drawInspector(screen, centerCam)
+	e.drawVersionDiff(screen, centerCam)
+
 	// draw tool cursor
-	op := &ebiten.DrawImageOptions{}
-	op.ColorM.Translate(0, 0, 0, -.5)
-	op.GeoM.Translate(-tileSize/2, -tileSize/2) // center to cursor
-	op.GeoM.Scale(e.zoom, e.zoom)
-	mx, my := ebiten.CursorPosition()
-	op.GeoM.Translate(float64(mx), float64(my))
-	sx := (int(e.block) % tileXNum) * tileSize
-	sy := (int(e.block) / tileXNum) * tileSize
-	screen.DrawImage(blocksImage.SubImage(image.Rect(sx, sy, sx+tileSize, sy+tileSize)).(*ebiten.Image), op)
+	_, art := e.layer.art()
+	switch {
+	case art:
+	case e.tool == toolFill, e.tool == toolLine, e.tool == toolRect, e.tool == toolFilledRect:
+		e.drawShape(screen, centerCam)
+	case e.tool == toolPaint:
+		op := &ebiten.DrawImageOptions{}
+		op.ColorM.Translate(0, 0, 0, -.5)
+		op.GeoM.Translate(-tileSize/2, -tileSize/2) // center to cursor
+		op.GeoM.Scale(e.zoom, e.zoom)
+		mx, my := ebiten.CursorPosition()
+		op.GeoM.Translate(float64(mx), float64(my))
+		screen.DrawImage(blockImage(int(e.block)), op)
+	}
 
+	e.drawRulers(screen, centerCam)
 	e.mgr.EndFrame(screen)
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("cam=%.1f,%.1f zoom=%.1f\ntps=%.2f", e.cam.Element(0, 2), e.cam.Element(1, 2), e.zoom, ebiten.CurrentTPS()))
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("cam=%.1f,%.1f zoom=%.1f\ntps=%.2f", e.cam.Element(0, 2), e.cam.Element(1, 2), e.zoom, ebiten.CurrentTPS()), rulerSize*2+2, rulerSize)
 }
 
 func (e *Editor) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
@@ -267,16 +469,48 @@ func (e *Editor) drawUI() {
 		}
 	}
 	e.gotoPopup()
+	e.restorePopup()
+	e.unlockPopup()
+	e.pollSessions()
 
 	if imgui.Begin("Toolbar") {
 		if imgui.BeginTabBar("Tools") {
 			if imgui.BeginTabItem("Art00") {
+				e.artTab(layerArt00)
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("Art0") {
+				e.artTab(layerArt0)
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("Blocks") {
+				e.layer = layerBlocks
+				for i, name := range tools {
+					if i > 0 {
+						imgui.SameLine()
+					}
+					if imgui.RadioButton(name, e.tool == tool(i)) {
+						e.tool = tool(i)
+					}
+				}
+				if e.tool == toolSelect {
+					if imgui.Button("Copy") {
+						e.copySelection()
+					}
+					imgui.SameLine()
+					if imgui.Button("Cut") {
+						e.cutSelection()
+					}
+					imgui.SameLine()
+					if imgui.Button("Paste") && e.sel.active {
+						e.paste(e.sel.rect.origin())
+					}
+					imgui.SameLine()
+					if imgui.Button("Delete") {
+						e.deleteSelection()
+					}
+				}
+
 				if imgui.BeginCombo("Block", blocks[e.block]) {
 					for id, name := range blocks {
 						if imgui.Selectable(name) {
@@ -305,44 +539,29 @@ func (e *Editor) drawUI() {
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("Art1") {
+				e.artTab(layerArt1)
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("Art2") {
+				e.artTab(layerArt2)
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("Art3") {
+				e.artTab(layerArt3)
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("BG") {
+				e.layer = layerBG
 				var bgc [3]float32 = coltof3(e.Course.BackgroundColor)
-				if imgui.ColorEdit3V("Background Color", &bgc, imgui.ColorEditFlagsHEX) {
-					e.Course.BackgroundColor = f3tocol(bgc)
+				if imgui.ColorEdit3V("Background Color", &bgc, imgui.ColorEditFlagsHEX) && !e.locked(layerBG) {
+					e.do(&bgEdit{before: e.Course.BackgroundColor, after: f3tocol(bgc)})
 				}
 				imgui.EndTabItem()
 			}
 			if imgui.BeginTabItem("Settings") {
-				if imgui.BeginCombo("Music", songs[e.song]) {
-					for id, name := range songs {
-						if imgui.Selectable(name) {
-							e.song = id
-						}
-					}
-					imgui.EndCombo()
-				}
-				if imgui.CollapsingHeader("Items") {
-					switch {
-					case
-						imgui.Checkbox("Laser Gun", &e.items[0]),
-						imgui.Checkbox("Speed Burst", &e.items[1]),
-						imgui.Checkbox("Jet Pack", &e.items[2]),
-						imgui.Checkbox("Super Jump", &e.items[3]),
-						imgui.Checkbox("Lightning", &e.items[4]),
-						imgui.Checkbox("Sword", &e.items[5]),
-						imgui.Checkbox("Teleport", &e.items[6]),
-						imgui.Checkbox("Mine", &e.items[7]),
-						imgui.Checkbox("Ice Wave", &e.items[8]):
-					}
-				}
+				// the blocks stay editable while changing settings
+				e.layer = layerBlocks
+				e.settingsTab()
 				imgui.EndTabItem()
 			}
 			imgui.EndTabBar()
@@ -350,11 +569,18 @@ func (e *Editor) drawUI() {
 	}
 	imgui.End()
 
+	e.layersWindow()
+	e.inspectorWindow()
+	e.diagnosticsWindow()
+	e.versionsWindow()
+	e.statusBar()
+
 	flags := imgui.WindowFlagsNoCollapse | imgui.WindowFlagsNoTitleBar |
 		imgui.WindowFlagsAlwaysAutoResize
 	if imgui.BeginV("Functionbar", nil, flags) {
 
 		if imgui.Button("Login") {
+			e.loginstatus = ""
 			imgui.OpenPopup(PopupLogin)
 		}
 		e.loginPopup()
@@ -368,14 +594,40 @@ func (e *Editor) drawUI() {
 
 		imgui.SameLine()
 
-		if imgui.Button("Load File") {
+		if imgui.Button("Save File") {
+			e.save()
+		}
+		imgui.SameLine()
+		if imgui.Button("Save As") {
+			e.openSaveAs = true
+		}
+		e.saveAsPopup()
 
+		imgui.SameLine()
+
+		if imgui.Button("Export PNG") {
+			e.openExport = true
 		}
+		e.exportPopup()
+
+		imgui.SameLine()
+
+		if imgui.Button("Check") {
+			e.validate()
+		}
+
+		imgui.SameLine()
+
+		if imgui.Button("Load File") {
+			e.files.open(e.files.dir)
+			imgui.OpenPopup(PopupLoadFile)
+		}
+		e.loadFilePopup()
 
 		imgui.SameLine()
 
 		if imgui.Button("Load") {
-			e.req = pr2hub.LevelsGet()
+			e.levelsReq = e.hub.LevelsGet(context.Background())
 			e.levelsnames = []string{"Loading..."}
 			e.levelsgotten = false
 			imgui.OpenPopup(PopupLoad)
@@ -384,19 +636,32 @@ func (e *Editor) drawUI() {
 
 		imgui.SameLine()
 
+		if imgui.Button("Browse") {
+			e.openBrowser()
+		}
+		e.browsePopup()
+
+		imgui.SameLine()
+
+		if imgui.Button("Versions") {
+			e.showVersions = !e.showVersions
+		}
+
+		imgui.SameLine()
+
 		if imgui.Button("New") {
-			e.loadCourse(course.Default())
+			e.loadCourse(level.New())
 		}
 
 		imgui.SameLine()
 
 		preview := "not logged in"
 		if sel := e.config.selectedAcc(); sel != -1 {
-			preview = e.config.Accs[sel].User
+			preview = accLabel(e.config.Accs[sel])
 		}
 		if imgui.BeginCombo("", preview) {
 			for i, acc := range e.config.Accs {
-				if imgui.Selectable(acc.User) {
+				if imgui.Selectable(accLabel(acc)) {
 					e.updateSelectedAcc(i)
 				}
 			}
@@ -417,37 +682,70 @@ func (e *Editor) drawUI() {
 	imgui.End()
 }
 
+// hubTimeout gives up on requests to a hub that stopped responding.
+const hubTimeout = 30 * time.Second
+
 const (
 	PopupSave                  = "PopupSave"
 	PopupSaveProgress          = "PopupSaveProgress"
+	PopupSaveInvalid           = "PopupSaveInvalid"
 	PopupSaveResponse          = "PopupSaveResponse"
 	PopupSaveOverwriteExisting = "PopupSaveOverwriteExisting"
 	PopupSaveOverrideBanned    = "PopupSaveOverrideBanned"
 	PopupSaveBanned            = "PopupSaveBanned"
 )
 
+// upload checks that the selected account is still logged in and then
+// sends the course, see PopupSaveProgress.
+func (e *Editor) upload() {
+	acc := e.config.Accs[e.config.selectedAcc()]
+	e.saveReq = nil
+	e.sessionReq = e.hub.CheckLogin(context.Background(), acc.Token)
+}
+
+// sendLevel sends the course to the hub as the selected account.
+func (e *Editor) sendLevel() {
+	acc := e.config.Accs[e.config.selectedAcc()]
+	val := e.current().Values(acc.User)
+	val.Set("token", acc.Token)
+	val.Set("override_existing", "0")
+	val.Set("override_banned", "0")
+	e.saveReq = e.hub.UploadLevel(context.Background(), val.Encode())
+}
+
 func (e *Editor) savePopup() {
 	// PopupSave
 	imgui.SetNextWindowSize(imgui.Vec2{X: 300, Y: 0})
 	if imgui.BeginPopupModalV(PopupSave, nil, imgui.WindowFlagsNone) {
-		imgui.InputText("Title", &e.Course.Title)
-		imgui.InputTextMultiline("Note", &e.Course.Note)
-		imgui.Checkbox("Publish", &e.Course.Live)
+		if e.readOnly {
+			imgui.Text("This level is open read-only, edit a copy to save it.")
+			if imgui.Button("OK") {
+				imgui.CloseCurrentPopup()
+			}
+			imgui.EndPopup()
+			return
+		}
+		title, note := e.Course.Title, e.Course.Note
+		if imgui.InputText("Title", &title) {
+			e.do(&textEdit{name: "title", field: courseTitle, before: e.Course.Title, after: title})
+		}
+		if imgui.InputTextMultiline("Note", &note) {
+			e.do(&textEdit{name: "note", field: courseNote, before: e.Course.Note, after: note})
+		}
+		if live := e.Course.Live; imgui.Checkbox("Publish", &live) {
+			e.do(&liveEdit{before: e.Course.Live, after: live})
+		}
 		if imgui.Button("Save") {
 			if sel := e.config.selectedAcc(); sel != -1 {
-				acc := e.config.Accs[sel]
-				// log.Println("save", acc.User, acc.Token)
-				val := e.Course.Values(acc.User)
-				val.Set("token", acc.Token)
-				val.Set("override_existing", "0")
-				val.Set("override_banned", "0")
-				var err error
-				e.req, err = pr2hub.UploadLevel(val.Encode())
-				if err != nil {
-					log.Println(err)
-				}
 				imgui.CloseCurrentPopup()
-				defer imgui.OpenPopup(PopupSaveProgress)
+				if acc := e.config.Accs[sel]; acc.expired || acc.Token == "" {
+					e.promptLogin(acc)
+				} else if e.validate(); level.Errors(e.diagnostics) > 0 {
+					defer imgui.OpenPopup(PopupSaveInvalid)
+				} else {
+					e.upload()
+					defer imgui.OpenPopup(PopupSaveProgress)
+				}
 			}
 		}
 		imgui.SameLine()
@@ -456,27 +754,53 @@ func (e *Editor) savePopup() {
 		}
 		imgui.EndPopup()
 	}
+	// PopupSaveInvalid
+	invalidMessage := fmt.Sprintf("The level has %d problems that will likely break it, see Diagnostics. Do you want to upload it anyway?", level.Errors(e.diagnostics))
+	if open, yes := yesnoPopup(PopupSaveInvalid, invalidMessage); open && yes {
+		e.upload()
+		defer imgui.OpenPopup(PopupSaveProgress)
+	}
 	// PopupSaveProgress
 	if imgui.BeginPopupModalV(PopupSaveProgress, nil, imgui.WindowFlagsAlwaysAutoResize) {
-		if e.req.Done(&e.saveresp) {
-			if err := e.req.Err(); err != nil {
-				log.Println(err)
-			} else {
-				log.Println(e.saveresp)
+		if req := e.sessionReq; req != nil {
+			if req.Done() {
+				e.sessionReq = nil
+				acc := e.config.Accs[e.config.selectedAcc()]
+				if err := req.Err(); err != nil {
+					// let the upload report what's wrong with the hub
+					log.Println(err)
+					e.sendLevel()
+				} else if !sessionValid(req.Resp, acc.User) {
+					imgui.CloseCurrentPopup()
+					e.sessionExpired(acc.User, acc.Token)
+				} else {
+					e.sendLevel()
+				}
 			}
+			imgui.Text(fmt.Sprintf("Checking the login... %c", spinner()))
+		} else {
+			if e.saveReq.Done() {
+				e.saveresp = e.saveReq.Resp
+				if err := e.saveReq.Err(); err != nil {
+					log.Println(err)
+					e.saveresp = url.Values{"message": {err.Error()}}
+				} else {
+					log.Println(e.saveresp)
+				}
 
-			switch status := e.saveresp.Get("status"); status {
-			case "exists":
-				defer imgui.OpenPopup(PopupSaveOverwriteExisting)
-			case "banned":
-				defer imgui.OpenPopup(PopupSaveOverrideBanned)
-			default:
-				defer imgui.OpenPopup(PopupSaveResponse)
+				switch status := e.saveresp.Get("status"); status {
+				case "exists":
+					defer imgui.OpenPopup(PopupSaveOverwriteExisting)
+				case "banned":
+					defer imgui.OpenPopup(PopupSaveOverrideBanned)
+				default:
+					defer imgui.OpenPopup(PopupSaveResponse)
+				}
+				imgui.CloseCurrentPopup()
 			}
-			imgui.CloseCurrentPopup()
-		}
 
-		imgui.Text(fmt.Sprintf("Saving the level... %c", spinner()))
+			imgui.Text(fmt.Sprintf("Saving the level... %c", spinner()))
+		}
 		imgui.EndPopup()
 	}
 	// PopupSaveOverwriteExisting
@@ -484,14 +808,10 @@ func (e *Editor) savePopup() {
 	if open, yes := yesnoPopup(PopupSaveOverwriteExisting, existingMessage); open {
 		if yes {
 			acc := e.config.Accs[e.config.selectedAcc()]
-			val := e.Course.Values(acc.User)
+			val := e.current().Values(acc.User)
 			val.Set("token", acc.Token)
 			val.Set("overwrite_existing", "1")
-			var err error
-			e.req, err = pr2hub.UploadLevel(val.Encode())
-			if err != nil {
-				log.Println(err)
-			}
+			e.saveReq = e.hub.UploadLevel(context.Background(), val.Encode())
 			defer imgui.OpenPopup(PopupSaveProgress)
 		}
 	}
@@ -500,14 +820,10 @@ func (e *Editor) savePopup() {
 	// if open, yes := yesnoPopup(PopupSaveOverwriteExisting, existingMessage); open {
 	// 	if yes {
 	// 		acc := e.config.Accs[e.config.SelectedAcc]
-	// 		val := e.Course.Values(acc.User)
+	// 		val := e.current().Values(acc.User)
 	// 		val.Set("token", acc.Token)
 	// 		val.Set("overwrite_existing", "1")
-	// 		var err error
-	// 		e.req, err = pr2hub.UploadLevel(val.Encode())
-	// 		if err != nil {
-	// 			log.Println(err)
-	// 		}
+	// 		e.saveReq = e.hub.UploadLevel(context.Background(), val.Encode())
 	// 		defer imgui.OpenPopup(PopupSaveProgress)
 	// 	}
 	// }
@@ -548,14 +864,15 @@ const (
 
 func (e *Editor) loadPopup() {
 	// PopupLoad
-	imgui.SetNextWindowSize(imgui.Vec2{X: 400, Y: 0})
+	imgui.SetNextWindowSize(imgui.Vec2{X: 640, Y: 0})
 	if imgui.BeginPopupModalV(PopupLoad, nil, imgui.WindowFlagsNone) {
 		if !e.levelsgotten {
 			e.levelsnames[0] = fmt.Sprintf("Loading... %c", spinner())
-			if e.req.Done(&e.levelsgetresp) {
+			if e.levelsReq.Done() {
 				// we got the levels, now stop checking req.Done()
 				e.levelsgotten = true
-				if err := e.req.Err(); err != nil {
+				e.levelsgetresp = e.levelsReq.Resp
+				if err := e.levelsReq.Err(); err != nil {
 					log.Println(err)
 				} else {
 					r := e.levelsgetresp
@@ -572,70 +889,129 @@ func (e *Editor) loadPopup() {
 			}
 		}
 
-		imgui.ColumnsV(2, "col", true)
+		if imgui.BeginTabBar("Load") {
+			if imgui.BeginTabItem("pr2hub") {
+				imgui.ColumnsV(2, "col", true)
 
-		imgui.PushItemWidth(-1) // don't show label
-		imgui.ListBoxV("", &e.levelsselected, e.levelsnames, 20)
-		imgui.PopItemWidth()
+				imgui.PushItemWidth(-1) // don't show label
+				imgui.ListBoxV("", &e.levelsselected, e.levelsnames, 20)
+				imgui.PopItemWidth()
 
-		imgui.NextColumn()
-		if e.levelsgotten && int(e.levelsselected) < len(e.levelsgetresp.Levels) {
-			imgui.PushTextWrapPos()
-			imgui.Text(e.levelsgetresp.Levels[e.levelsselected].Note)
-			imgui.PopTextWrapPos()
-		}
-		imgui.Columns()
-		imgui.Separator()
-		if imgui.Button("Load##2") && int(e.levelsselected) < len(e.levelsgetresp.Levels) {
-			level := e.levelsgetresp.Levels[e.levelsselected]
-			e.req = pr2hub.Level(level.LevelID, level.Version)
-			imgui.CloseCurrentPopup()
-			defer imgui.OpenPopup(PopupLoadProgress)
-		}
-		imgui.SameLine()
+				imgui.NextColumn()
+				if e.levelsgotten && int(e.levelsselected) < len(e.levelsgetresp.Levels) {
+					imgui.PushTextWrapPos()
+					imgui.Text(e.levelsgetresp.Levels[e.levelsselected].Note)
+					imgui.PopTextWrapPos()
+				}
+				imgui.Columns()
+				imgui.Separator()
+				if imgui.Button("Load##2") && int(e.levelsselected) < len(e.levelsgetresp.Levels) {
+					level := e.levelsgetresp.Levels[e.levelsselected]
+					e.levelReq = e.hub.Level(context.Background(), level.LevelID, level.Version)
+					e.dlinfo = level
+					imgui.CloseCurrentPopup()
+					defer imgui.OpenPopup(PopupLoadProgress)
+				}
+				imgui.SameLine()
 
-		if imgui.Button("Delete") && int(e.levelsselected) < len(e.levelsgetresp.Levels) {
-			level := e.levelsgetresp.Levels[e.levelsselected]
-			token := e.config.Accs[e.config.selectedAcc()].Token
-			var err error
-			e.req, err = pr2hub.DeleteLevel(level.LevelID, token)
-			if err != nil {
-				log.Println(err)
-			}
-			imgui.OpenPopup(PopupDelete)
-		}
-		e.deletePopup()
+				if imgui.Button("Delete") && int(e.levelsselected) < len(e.levelsgetresp.Levels) {
+					level := e.levelsgetresp.Levels[e.levelsselected]
+					token := e.config.Accs[e.config.selectedAcc()].Token
+					e.deleteReq = e.hub.DeleteLevel(context.Background(), level.LevelID, token)
+					imgui.OpenPopup(PopupDelete)
+				}
+				e.deletePopup()
 
-		imgui.SameLine()
-		if imgui.Button("Cancel") {
-			e.levelsgotten = false
-			e.req.Cancel()
-			imgui.CloseCurrentPopup()
+				imgui.SameLine()
+				if imgui.Button("Cancel") {
+					e.levelsgotten = false
+					e.levelsReq.Cancel()
+					imgui.CloseCurrentPopup()
+				}
+				imgui.EndTabItem()
+			}
+			if imgui.BeginTabItem("Library") {
+				e.libraryTab()
+				imgui.EndTabItem()
+			}
+			imgui.EndTabBar()
 		}
 
 		imgui.EndPopup()
 	}
 	// PopupLoadProgress
 	if imgui.BeginPopupModalV(PopupLoadProgress, nil, imgui.WindowFlagsAlwaysAutoResize) {
-		if e.req.Done(&e.dllevel) {
+		if e.levelReq.Done() {
 			e.dldone = true
-			if err := e.req.Err(); err != nil {
+			e.dllevel = e.levelReq.Data
+			if err := e.levelReq.Err(); err != nil {
 				log.Println(err)
 			} else {
-				c, err := course.Parse(e.dllevel)
+				l, err := level.Parse(e.dllevel)
 				if err != nil {
 					log.Println(err)
+				} else {
+					e.loadCourse(l)
+					e.setOrigin(e.dlinfo)
 				}
-				e.loadCourse(c)
 			}
 			imgui.CloseCurrentPopup()
 		}
 
 		imgui.Text(fmt.Sprintf("Downloading the level... %c", spinner()))
+		if imgui.Button("Cancel") {
+			e.levelReq.Cancel()
+		}
 		imgui.EndPopup()
 	}
 }
 
+const (
+	PopupLoadFile        = "Load File##PopupLoadFile"
+	PopupLoadFileFailure = "Load failed##PopupLoadFileFailure"
+)
+
+func (e *Editor) loadFilePopup() {
+	// PopupLoadFile
+	imgui.SetNextWindowSize(imgui.Vec2{X: 500, Y: 0})
+	if imgui.BeginPopupModalV(PopupLoadFile, nil, imgui.WindowFlagsNone) {
+		chosen := e.files.draw()
+		if imgui.Button("Open") || chosen {
+			if err := e.loadFile(e.files.path()); err != nil {
+				e.fileerr = err.Error()
+				log.Println(err)
+				defer imgui.OpenPopup(PopupLoadFileFailure)
+			}
+			imgui.CloseCurrentPopup()
+		}
+		imgui.SameLine()
+		if imgui.Button("Cancel") {
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+	// PopupLoadFileFailure
+	if imgui.BeginPopupModalV(PopupLoadFileFailure, nil, imgui.WindowFlagsAlwaysAutoResize) {
+		imgui.Text(e.fileerr)
+		if imgui.Button("OK") {
+			imgui.CloseCurrentPopup()
+			defer imgui.OpenPopup(PopupLoadFile)
+		}
+		imgui.EndPopup()
+	}
+}
+
+// loadFile opens a level saved in the same text format as pr2hub serves.
+func (e *Editor) loadFile(path string) error {
+	l, err := level.Load(path)
+	if err != nil {
+		return err
+	}
+	e.loadCourse(l)
+	e.path = path
+	return nil
+}
+
 const (
 	PopupDelete         = "PopupDelete"
 	PopupDeleteProgress = "PopupDeleteProgress"
@@ -659,13 +1035,14 @@ func (e *Editor) deletePopup() {
 	}
 	// PopupDeleteProgress
 	if imgui.BeginPopupModalV(PopupDeleteProgress, nil, imgui.WindowFlagsAlwaysAutoResize) {
-		if e.req.Done(&e.deleteresp) {
-			if err := e.req.Err(); err != nil {
+		if e.deleteReq.Done() {
+			e.deleteresp = e.deleteReq.Resp
+			if err := e.deleteReq.Err(); err != nil {
 				log.Println(err)
 			} else {
 				if e.deleteresp.Success {
 					// refresh levels list
-					e.req = pr2hub.LevelsGet()
+					e.levelsReq = e.hub.LevelsGet(context.Background())
 					e.levelsnames = []string{"Loading..."}
 					e.levelsgotten = false
 				} else {
@@ -726,21 +1103,28 @@ const (
 )
 
 func (e *Editor) loginPopup() {
+	if e.openLogin {
+		e.openLogin = false
+		imgui.OpenPopup(PopupLogin)
+	}
 	// PopupLogin
 	if imgui.BeginPopupModalV(PopupLogin, nil, imgui.WindowFlagsAlwaysAutoResize) {
+		if e.loginstatus != "" {
+			imgui.Text(e.loginstatus)
+		}
+		if e.config.locked() {
+			// a new token couldn't be saved, so log in once it can
+			e.lockedLogin()
+			imgui.EndPopup()
+			return
+		}
 		imgui.InputText("user", &e.loginuser)
 		imgui.InputTextV("pass", &e.loginpass, imgui.InputTextFlagsPassword, nil)
 		// imgui.Checkbox("remember?", &e.loginremember)
 		if imgui.Button("Log In") {
-			var err error
-			e.req, err = pr2hub.Login(e.loginuser, e.loginpass, e.loginremember)
-			if err == nil {
-				imgui.CloseCurrentPopup()
-				defer imgui.OpenPopup(PopupLoginProgress)
-			} else {
-				e.loginstatus = err.Error()
-				log.Println(err)
-			}
+			e.loginReq = e.hub.Login(context.Background(), e.loginuser, e.loginpass, e.loginremember)
+			imgui.CloseCurrentPopup()
+			defer imgui.OpenPopup(PopupLoginProgress)
 		}
 		imgui.SameLine()
 		if imgui.Button("Cancel") {
@@ -750,17 +1134,16 @@ func (e *Editor) loginPopup() {
 	}
 	// PopupLoginProgress
 	if imgui.BeginPopupModalV(PopupLoginProgress, nil, imgui.WindowFlagsAlwaysAutoResize) {
-		var resp pr2hub.LoginResponse
-		if e.req.Done(&resp) {
-			if err := e.req.Err(); err != nil {
+		if e.loginReq.Done() {
+			resp := e.loginReq.Resp
+			if err := e.loginReq.Err(); err != nil {
 				e.loginstatus = fmt.Sprint("error:", err)
 				log.Println(err)
 			} else {
 				if resp.Success {
 					e.loginstatus = fmt.Sprint("Login successful ")
-					acc := Acc{e.loginuser, resp.Token}
-					e.config.Accs = append(e.config.Accs, acc)
-					e.updateSelectedAcc(len(e.config.Accs) - 1)
+					redact(resp.Token)
+					e.addAcc(Acc{User: e.loginuser, Token: resp.Token})
 				} else {
 					e.loginstatus = resp.Error
 					defer imgui.OpenPopup(PopupLoginFailure)
@@ -770,7 +1153,7 @@ func (e *Editor) loginPopup() {
 		} else {
 			imgui.Text(fmt.Sprintf("Logging in... %c", spinner()))
 			if imgui.Button("Cancel") {
-				e.req.Cancel()
+				e.loginReq.Cancel()
 				imgui.CloseCurrentPopup()
 				defer imgui.OpenPopup(PopupLogin)
 			}
@@ -799,63 +1182,83 @@ func (e *Editor) updateSelectedAcc(selected int) {
 func (e *Editor) loadSelectedAcc() {
 	if sel := e.config.selectedAcc(); sel != -1 {
 		acc := e.config.Accs[sel]
-		u, err := url.Parse("https://pr2hub.com")
-		if err != nil {
-			log.Fatalln(err)
-		}
-		cookie := &http.Cookie{Name: "token", Value: acc.Token}
-		http.DefaultClient.Jar, err = cookiejar.New(nil)
-		if err != nil {
+		if err := e.hub.SetToken(acc.Token); err != nil {
 			log.Fatalln(err)
 		}
-		http.DefaultClient.Jar.SetCookies(u, []*http.Cookie{cookie})
-		log.Printf("Logged in as %s:%s\n", acc.User, acc.Token)
+		log.Println("Logged in as", acc.User)
 	} else {
-		http.DefaultClient.Jar = nil
+		e.hub.SetToken("")
 	}
 }
 
 func main() {
+	hub := flag.String("hub", "", "pr2hub server to use instead of the one in the config")
+	flag.Parse()
+
 	ebiten.SetWindowSize(1280, 960)
-	ebiten.SetWindowTitle(fmt.Sprintf("%s v%s", AppName, AppVersion))
 	ebiten.SetWindowResizable(true)
 
 	cfg, err := LoadConfig()
 	if err != nil {
 		log.Println(err)
 	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if *hub == "" {
+		*hub = cfg.hub()
+	}
 
 	e := &Editor{
-		mgr:    renderer.New(nil),
-		zoom:   1,
-		config: cfg,
+		mgr:          renderer.New(nil),
+		zoom:         1,
+		config:       cfg,
+		hub:          pr2hub.NewClient(*hub, nil),
+		exportScale:  1,
+		artThickness: 4,
+		showGrid:     true,
+		layers:       defaultLayers(),
 	}
+	e.hub.Timeout = hubTimeout
+	e.unlockConfig()
 	e.loadSelectedAcc()
+	e.checkSessions()
 
-	// resp, err := pr2hub.CheckLogin()
-	// if err == nil {
-	// 	if len(resp.UserName) != 0 {
-	// 		log.Println("Logged in as", resp.UserName)
-	// 	}
-	// 	// cfg.CheckLoginResponse = *resp
-	// } else {
-	// 	log.Println("CheckLogin failed:", err)
-	// }
+	e.loadCourse(level.New())
+	e.updateTitle()
 
-	e.loadCourse(course.Default())
+	if j, err := loadJournal(); err == nil {
+		e.restore = j
+		e.openRestore = true
+	} else if !os.IsNotExist(err) {
+		log.Println(err)
+	}
 
 	for i, subimg := range blockImgs {
 		// id := imgui.TextureID((unsafe.Pointer(subimg)))
 		e.mgr.Cache.SetTexture(imgui.TextureID(100+i), subimg)
 	}
 
-	if err := ebiten.RunGame(e); err != nil {
+	err = ebiten.RunGame(e)
+	// keep unsaved work for the next launch
+	e.autosave(true)
+	if err != nil {
 		panic(err)
 	}
 }
 
-func (e *Editor) loadCourse(c *course.Course) {
-	e.Course = c
+func (e *Editor) loadCourse(l *level.Level) {
+	e.Course = l.Course
+	e.opts = l.Settings
+	e.art = l.Art
+	e.line = nil
+	e.readOnly = false
+	e.origin = origin{}
+	e.inspector.pinned = false
+	e.stroke = nil
+	e.history.reset()
+	e.deselect()
+	e.path = ""
 	e.gotoBlock(course.BlockPlayer1)
 }
 
@@ -897,6 +1300,17 @@ func spinner() byte {
 	return "|/-\\"[int(imgui.Time()/0.05)&3]
 }
 
+// blockImage returns the atlas tile for the block id.
+func blockImage(id int) *ebiten.Image {
+	id = level.BlockID(id)
+	if img, ok := blockImgs[id]; ok {
+		return img
+	}
+	sx := (id % tileXNum) * tileSize
+	sy := (id / tileXNum) * tileSize
+	return blocksImage.SubImage(image.Rect(sx, sy, sx+tileSize, sy+tileSize)).(*ebiten.Image)
+}
+
 func xytof(xy course.XY) (x, y float64) {
 	return float64(xy.X), float64(xy.Y)
 }
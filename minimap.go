@@ -0,0 +1,179 @@
+package main
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+const (
+	// minimapTexture is the imgui texture ID of the minimap, clear of the
+	// block textures from 100 up.
+	minimapTexture = imgui.TextureID(1000)
+	// minimapSize is the most room the minimap takes either way, and the
+	// most pixels its image has.
+	minimapSize = 200
+	// minimapMaxScale stops small courses from becoming a few huge pixels.
+	minimapMaxScale = 8
+	// minimapMargin is how many blocks the minimap reaches past the course
+	// at least, so that building outwards doesn't rebuild it every time.
+	minimapMargin = 8
+)
+
+// minimap is the course scaled down to fit minimapSize.
+type minimap struct {
+	ov  *level.Overview
+	tex *ebiten.Image
+	// course is what ov shows, and stale is set when tex is behind ov.
+	course *course.Course
+	stale  bool
+}
+
+// fitScale returns how much to scale a map of w by h pixels to fit in the
+// room of the minimap.
+func fitScale(w, h int) float64 {
+	return math.Min(minimapMaxScale, math.Min(minimapSize/float64(w), minimapSize/float64(h)))
+}
+
+// minimapBounds returns the blocks the minimap of a course described by
+// info shows, in block units.
+func minimapBounds(info level.Info) image.Rectangle {
+	margin := func(size int) int {
+		if m := size / 8; m > minimapMargin {
+			return m
+		}
+		return minimapMargin
+	}
+	mx, my := margin(info.MaxX-info.MinX), margin(info.MaxY-info.MinY)
+	return image.Rect(info.MinX-mx, info.MinY-my, info.MaxX+mx+1, info.MaxY+my+1)
+}
+
+// buildMinimap maps the whole course, which is only needed when it is
+// replaced or outgrows the map.
+func (e *Editor) buildMinimap() {
+	m := &e.minimap
+	ov := level.NewOverview(minimapBounds(level.Describe(e.Course)), minimapSize, blockColors, e.Course.BackgroundColor)
+	for xy := range e.Course.Blocks {
+		ov.Set(cell(xy.X), cell(xy.Y), topBlock(e.Course, xy))
+	}
+	w, h := ov.Img.Rect.Dx(), ov.Img.Rect.Dy()
+	if m.tex != nil {
+		if tw, th := m.tex.Size(); tw != w || th != h {
+			m.tex.Dispose()
+			m.tex = nil
+		}
+	}
+	if m.tex == nil {
+		tex, err := ebiten.NewImage(w, h, ebiten.FilterNearest)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		m.tex = tex
+		e.mgr.Cache.SetTexture(minimapTexture, tex)
+	}
+	m.ov, m.course, m.stale = ov, e.Course, true
+}
+
+// topBlock returns the top block at xy, or -1 if there is none.
+func topBlock(c *course.Course, xy course.XY) int {
+	stack := c.Blocks[xy]
+	if len(stack) == 0 {
+		return -1
+	}
+	return stack[len(stack)-1].(int)
+}
+
+// minimapCell redraws the cell xy on the minimap after it changed.
+func (e *Editor) minimapCell(xy course.XY) {
+	m := &e.minimap
+	if m.ov == nil || m.course != e.Course {
+		return
+	}
+	if m.ov.Set(cell(xy.X), cell(xy.Y), topBlock(e.Course, xy)) {
+		m.stale = true
+	} else {
+		// the course grew past the map
+		m.ov = nil
+	}
+}
+
+// updateMinimap brings the minimap up to date with the course and the
+// stroke in progress.
+func (e *Editor) updateMinimap() {
+	m := &e.minimap
+	if m.ov == nil || m.course != e.Course {
+		e.buildMinimap()
+	}
+	if e.stroke != nil {
+		for xy := range e.stroke.after {
+			e.minimapCell(xy)
+		}
+		if m.ov == nil {
+			e.buildMinimap()
+		}
+	}
+	if m.ov == nil || m.tex == nil {
+		return
+	}
+	if m.ov.SetBackground(e.Course.BackgroundColor) {
+		m.stale = true
+	}
+	if m.stale {
+		m.tex.ReplacePixels(m.ov.Img.Pix)
+		m.stale = false
+	}
+}
+
+// minimapWindow shows the whole course and the part of it on screen.
+// Clicking or dragging in it moves the camera there.
+func (e *Editor) minimapWindow(screen *ebiten.Image) {
+	if !imgui.BeginV("Minimap", nil, imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoMove) {
+		imgui.End()
+		return
+	}
+	e.updateMinimap()
+	m := &e.minimap
+	if m.ov == nil || m.tex == nil {
+		imgui.End()
+		return
+	}
+	w, h := m.tex.Size()
+	// scale is screen pixels per block
+	scale := fitScale(w, h) / float64(m.ov.Scale)
+	origin := m.ov.Bounds.Min
+	pos := imgui.CursorScreenPos()
+	imgui.Image(minimapTexture, imgui.Vec2{X: float32(float64(w*m.ov.Scale) * scale), Y: float32(float64(h*m.ov.Scale) * scale)})
+
+	// world pixels to the screen position in the minimap, and back
+	toMap := func(x, y float64) imgui.Vec2 {
+		return imgui.Vec2{
+			X: pos.X + float32((x/tileSize-float64(origin.X))*scale),
+			Y: pos.Y + float32((y/tileSize-float64(origin.Y))*scale),
+		}
+	}
+	toWorld := func(p imgui.Vec2) (x, y float64) {
+		x = (float64(p.X-pos.X)/scale + float64(origin.X)) * tileSize
+		y = (float64(p.Y-pos.Y)/scale + float64(origin.Y)) * tileSize
+		return x, y
+	}
+
+	if imgui.IsItemHovered() && imgui.IsMouseDown(0) {
+		x, y := toWorld(imgui.MousePos())
+		setPos(&e.cam, -x, -y)
+	}
+
+	sw, sh := screen.Size()
+	x0, y0 := e.screenToWorld(screen, 0, 0)
+	x1, y1 := e.screenToWorld(screen, float64(sw), float64(sh))
+	if !math.IsNaN(x0) && !math.IsNaN(x1) {
+		clr := imgui.PackedColorFromVec4(imgui.Vec4{X: 1, Y: 1, Z: 0, W: 1})
+		imgui.WindowDrawList().AddRect(toMap(x0, y0), toMap(x1, y1), clr)
+	}
+	imgui.End()
+}
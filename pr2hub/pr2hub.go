@@ -10,11 +10,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
-	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -36,6 +37,20 @@ func encrypt(src string, key []byte) ([]byte, error) {
 	return crypted, nil
 }
 
+func decrypt(src []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(src)%block.BlockSize() != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+	cbc := cipher.NewCBCDecrypter(block, loginIV)
+	plain := make([]byte, len(src))
+	cbc.CryptBlocks(plain, src)
+	return zerosUnpad(plain), nil
+}
+
 // Pad the ciphertext with zeros until it is of length blockSize.
 func zerosPad(ciphertext []byte, blockSize int) []byte {
 	// determine number of zeros to add
@@ -50,6 +65,47 @@ func zerosUnpad(ciphertext []byte) []byte {
 	return bytes.TrimRight(ciphertext, string(byte(0)))
 }
 
+// LoginRequest is what Login encrypts into the "i" form field.
+type LoginRequest struct {
+	Build    string `json:"build"`
+	Domain   string `json:"domain"`
+	LoginID  int    `json:"login_id"`
+	Remember bool   `json:"remember"`
+	UserName string `json:"user_name"`
+	UserPass string `json:"user_pass"`
+	// Server is the game server being joined.
+	Server json.RawMessage `json:"server"`
+}
+
+// loginServer is the server that Login says it joins.
+var loginServer = json.RawMessage(`{
+	"port":9160,
+	"status":"open",
+	"server_id":1,
+	"happy_hour":0,
+	"server_name":"Derron",
+	"address":"45.76.24.255",
+	"guild_id":0,
+	"tournament":"0",
+	"population":40
+}`)
+
+// DecodeLogin decrypts the "i" form field sent by Login. It is meant for
+// servers, like the fake hub in pr2hubtest.
+func DecodeLogin(i string) (*LoginRequest, error) {
+	b, err := base64.RawStdEncoding.DecodeString(i)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decrypt(b, loginKey)
+	if err != nil {
+		return nil, err
+	}
+	var r LoginRequest
+	err = json.Unmarshal(plain, &r)
+	return &r, err
+}
+
 type LoginResponse struct {
 	Success        bool        `json:"success"`
 	Error          string      `json:"error"`
@@ -69,434 +125,273 @@ type LoginResponse struct {
 }
 
 const (
-	build   = "22-jun-2020-v160"
-	referer = "https://pr2hub.com/"
+	// DefaultBaseURL is the address of the official hub.
+	DefaultBaseURL = "https://pr2hub.com"
+	// DefaultBuild is the game version that the client pretends to be.
+	DefaultBuild = "22-jun-2020-v160"
 )
 
-func Login(user, pass string, remember bool) (*Req, error) {
-	const j = `{
-		"build":"%s",
-		"domain":"pr2hub.com",
-		"login_id":12985,
-		"remember":%t,
-		"user_name":"%s",
-		"user_pass":"%s",
-		"server":{
-			"port":9160,
-			"status":"open",
-			"server_id":1,
-			"happy_hour":0,
-			"server_name":"Derron",
-			"address":"45.76.24.255",
-			"guild_id":0,
-			"tournament":"0",
-			"population":40
-		}
-	}`
-	b, err := encrypt(fmt.Sprintf(j, build, remember, user, pass), loginKey)
-	if err != nil {
-		return nil, err
-	}
-	i := base64.RawStdEncoding.EncodeToString(b)
+// Client talks to a pr2hub server. Each Client has its own cookie jar, so
+// several accounts can be used side by side.
+type Client struct {
+	// BaseURL is the address of the hub, without a trailing slash.
+	BaseURL string
+	// Build is the game version sent with logins.
+	Build string
+	HTTP  *http.Client
+	// Timeout limits each request, unless it is zero.
+	Timeout time.Duration
+}
 
-	form := make(url.Values)
-	form.Add("build", build)
-	form.Add("i", i)
-	req, err := http.NewRequest("POST", "https://pr2hub.com/login.php", strings.NewReader(form.Encode()))
-	if err != nil {
-		return nil, err
+// NewClient returns a client for the hub at baseURL. A copy of hc is used
+// with a fresh cookie jar, or a new http.Client if hc is nil.
+func NewClient(baseURL string, hc *http.Client) *Client {
+	var h http.Client
+	if hc != nil {
+		h = *hc
+	}
+	h.Jar, _ = cookiejar.New(nil) // never fails
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Build:   DefaultBuild,
+		HTTP:    &h,
 	}
-	req.Header.Add("Referer", referer)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	var r LoginResponse
-	return httpReq2(req, &r), nil
 }
 
-func UploadLevel(data string) (*Req, error) {
-	req, err := http.NewRequest("POST", "https://pr2hub.com/upload_level.php", strings.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	values := make(url.Values)
-	return httpReq3(req, queryUnmarshal, &values), nil
+func (c *Client) url(path string) string {
+	return c.BaseURL + "/" + path
 }
 
-type DeleteLevelResponse jsonResponse
+func (c *Client) referer() string {
+	return c.BaseURL + "/"
+}
 
-func DeleteLevel(levelID, token string) (*Req, error) {
-	body := make(url.Values)
-	body.Set("level_id", levelID)
-	body.Set("token", token)
-	req, err := http.NewRequest("POST", "https://pr2hub.com/delete_level.php", strings.NewReader(body.Encode()))
+func (c *Client) domain() string {
+	u, err := url.Parse(c.BaseURL)
 	if err != nil {
-		return nil, err
+		return ""
 	}
-	req.Header.Add("Referer", referer)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	var d DeleteLevelResponse
-	return httpReq3(req, jsonUnmarshal, &d), nil
+	return u.Hostname()
 }
 
-type CheckLoginResponse struct {
-	UserName string      `json:"user_name"`
-	GuildID  interface{} `json:"guild_id"`
+// SetToken logs the client in with a token from Login, or out if token is
+// empty. The cookies of the last account are expired in the client's jar,
+// which is never replaced, so requests can run while the token changes.
+func (c *Client) SetToken(token string) error {
+	jar := c.HTTP.Jar
+	if jar == nil {
+		return errors.New("the http.Client has no cookie jar")
+	}
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return err
+	}
+	var cookies []*http.Cookie
+	for _, old := range jar.Cookies(u) {
+		cookies = append(cookies, &http.Cookie{Name: old.Name, Path: "/", MaxAge: -1})
+	}
+	if token != "" {
+		cookies = append(cookies, &http.Cookie{Name: "token", Value: token, Path: "/"})
+	}
+	jar.SetCookies(u, cookies)
+	return nil
 }
 
-// func CheckLogin() (*CheckLoginResponse, error) {
-// 	resp, err := http.Get("https://pr2hub.com/check_login.php")
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	defer resp.Body.Close()
-// 	var r CheckLoginResponse
-// 	err = json.NewDecoder(resp.Body).Decode(&r)
-// 	return &r, err
-// }
-
-type LevelsGetResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
-	Levels  []struct {
-		LevelID   string  `json:"level_id"`
-		Version   string  `json:"version"`
-		Title     string  `json:"title"`
-		Rating    float64 `json:"rating"`
-		PlayCount string  `json:"play_count"`
-		MinLevel  string  `json:"min_level"`
-		Note      string  `json:"note"`
-		Live      string  `json:"live"`
-		Type      string  `json:"type"`
-		Time      string  `json:"time"`
-		Name      string  `json:"name"`
-		Power     string  `json:"power"`
-		TrialMod  string  `json:"trial_mod"`
-		UserID    string  `json:"user_id"`
-	} `json:"levels"`
+type LoginReq struct {
+	Req
+	Resp LoginResponse
 }
 
-func LevelsGet() *Req {
-	// var l LevelsGetResponse
-	// return httpReq(&l, "GET", "https://pr2hub.com/levels_get.php", nil)
-
-	req, err := http.NewRequest("GET", "https://pr2hub.com/levels_get.php", nil)
+func (c *Client) Login(ctx context.Context, user, pass string, remember bool) *LoginReq {
+	r := &LoginReq{}
+	j, err := json.Marshal(LoginRequest{
+		Build:    c.Build,
+		Domain:   c.domain(),
+		LoginID:  12985,
+		Remember: remember,
+		UserName: user,
+		UserPass: pass,
+		Server:   loginServer,
+	})
 	if err != nil {
-		log.Fatal(err)
+		r.fail(err)
+		return r
 	}
-	var l LevelsGetResponse
-	return httpReq3(req, jsonUnmarshal, &l)
-}
-
-// TODO: v exists so the unmarshalling happens in another goroutine,
-//       is this necessary?
-func httpReq3(request *http.Request, unmarshal UnmarshalFunc, v interface{}) *Req {
-	respCh := make(chan interface{})
-	ctx, cancel := context.WithCancel(context.Background())
-	req := &Req{ctx: ctx, cancel: cancel, respCh: respCh}
-	method, url := request.Method, request.URL.String()
-	wrap := func(err error) error {
-		return errors.Wrap(err, fmt.Sprintf("ERR %s %q", method, url))
+	b, err := encrypt(string(j), loginKey)
+	if err != nil {
+		r.fail(err)
+		return r
 	}
-	log.Println("} BEGIN {", method, url)
-
-	go func() {
-		defer close(respCh)
-		defer cancel()
-		defer log.Println("} CANCEL {", method, url)
+	i := base64.RawStdEncoding.EncodeToString(b)
 
-		resp, err := http.DefaultClient.Do(request)
-		if err != nil {
-			respCh <- err
-			return
-		}
-		defer resp.Body.Close()
+	form := make(url.Values)
+	form.Add("build", c.Build)
+	form.Add("i", i)
+	c.start(ctx, &r.Req, post("login.php", form.Encode()), jsonDecoder(&r.Resp))
+	return r
+}
 
-		if resp.StatusCode != http.StatusOK {
-			respCh <- wrap(fmt.Errorf("bad status: %s", resp.Status))
-			return
-		}
+type UploadLevelReq struct {
+	Req
+	// Resp has the "status" and "message" of the upload.
+	Resp url.Values
+}
 
-		err = unmarshal(resp.Body, v)
+// UploadLevel uploads a level encoded as a form, see course.Values.
+func (c *Client) UploadLevel(ctx context.Context, data string) *UploadLevelReq {
+	r := &UploadLevelReq{}
+	c.start(ctx, &r.Req, post("upload_level.php", data), func(body io.Reader) error {
+		b, err := ioutil.ReadAll(body)
 		if err != nil {
-			respCh <- err
-			return
+			return err
 		}
-		respCh <- v
-
-		log.Println("} SUCCESS {", method, url)
-	}()
-
-	return req
+		r.Resp, err = url.ParseQuery(string(b))
+		return err
+	})
+	return r
 }
 
-func httpReq2(request *http.Request, v interface{}) *Req {
-	respCh := make(chan interface{})
-	ctx, cancel := context.WithCancel(context.Background())
-	req := &Req{ctx: ctx, cancel: cancel, respCh: respCh}
-	method, url := request.Method, request.URL.String()
-	wrap := func(err error) error {
-		return errors.Wrap(err, fmt.Sprintf("ERR %s %q", method, url))
-	}
-	log.Println("} BEGIN {", method, url)
-
-	go func() {
-		defer close(respCh)
-		defer cancel()
-		defer log.Println("} CANCEL {", method, url)
+type DeleteLevelResponse jsonResponse
 
-		resp, err := http.DefaultClient.Do(request)
-		if err != nil {
-			respCh <- err
-			return
-		}
-		defer resp.Body.Close()
+type DeleteLevelReq struct {
+	Req
+	Resp DeleteLevelResponse
+}
 
-		if resp.StatusCode != http.StatusOK {
-			respCh <- wrap(fmt.Errorf("bad status: %s", resp.Status))
-			return
-		}
+func (c *Client) DeleteLevel(ctx context.Context, levelID, token string) *DeleteLevelReq {
+	body := make(url.Values)
+	body.Set("level_id", levelID)
+	body.Set("token", token)
+	r := &DeleteLevelReq{}
+	c.start(ctx, &r.Req, post("delete_level.php", body.Encode()), jsonDecoder(&r.Resp))
+	return r
+}
 
-		isJSON := (v != nil)
-		if isJSON {
-			err = json.NewDecoder(resp.Body).Decode(v)
-			if err != nil {
-				respCh <- err
-				return
-			}
-			respCh <- v
-		} else {
-			b, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				respCh <- err
-				return
-			}
-			respCh <- string(b)
-		}
-		log.Println("} SUCCESS {", method, url)
-	}()
+type CheckLoginResponse struct {
+	UserName string      `json:"user_name"`
+	GuildID  interface{} `json:"guild_id"`
+}
 
-	return req
+type CheckLoginReq struct {
+	Req
+	Resp CheckLoginResponse
 }
 
-// func httpNoReq(ctx context.Context, request *http.Request, v interface{}) func(interface{}, *error) bool {
-// 	respCh := make(chan interface{})
-// 	ctx, cancel := context.WithCancel(context.Background())
-// 	// req2 := &Req2{ctx: ctx, cancel: cancel, respCh: respCh}
-// 	method, url := request.Method, request.URL.String()
-// 	wrap := func(err error) error {
-// 		return errors.Wrap(err, fmt.Sprintf("ERR %s %q", method, url))
-// 	}
-// 	log.Println("} BEGIN {", method, url)
-
-// 	go func() {
-// 		defer close(respCh)
-// 		defer cancel()
-// 		defer log.Println("} CANCEL {", method, url)
-
-// 		resp, err := http.DefaultClient.Do(request)
-// 		if err != nil {
-// 			respCh <- err
-// 			return
-// 		}
-// 		defer resp.Body.Close()
-
-// 		if resp.StatusCode != http.StatusOK {
-// 			respCh <- wrap(fmt.Errorf("bad status: %s", resp.Status))
-// 			return
-// 		}
-
-// 		isJSON := (v != nil)
-// 		if isJSON {
-// 			err = json.NewDecoder(resp.Body).Decode(v)
-// 			if err != nil {
-// 				respCh <- err
-// 				return
-// 			}
-// 			respCh <- v
-// 		} else {
-// 			b, err := ioutil.ReadAll(resp.Body)
-// 			if err != nil {
-// 				respCh <- err
-// 				return
-// 			}
-// 			respCh <- string(b)
-// 		}
-// 		log.Println("} SUCCESS {", method, url)
-// 	}()
-
-// 	return func(v interface{}, errp *error) bool {
-// 		select {
-// 		case <-ctx.Done():
-// 			// request was canceled or timed out
-// 			// r.err = ctx.Err()
-// 			return true
-// 		case resp, notClosed := <-respCh:
-// 			if notClosed {
-// 				if err, ok := resp.(error); ok {
-// 					*errp = err
-// 					return true
-// 				}
-// 				// http request succeeded!
-// 				rv := reflect.ValueOf(v)
-// 				if rv.Kind() != reflect.Ptr || rv.IsNil() {
-// 					panic("v should be non-nil pointer")
-// 				}
-// 				// *v = *resp
-// 				if reflect.ValueOf(resp).Kind() == reflect.String {
-// 					rv.Elem().SetString(resp.(string))
-// 				} else {
-// 					rv.Elem().Set(reflect.ValueOf(resp).Elem())
-// 				}
-// 				return true
-// 			}
-// 			// we already succeeded, but ok
-// 			return true
-// 		default:
-// 			// still not done
-// 			return false
-// 		}
-// 	}
-// }
-
-func httpReq(v interface{}, method string, url string, body io.Reader) *Req {
-	respCh := make(chan interface{})
-	ctx, cancel := context.WithCancel(context.Background())
-	req := &Req{ctx: ctx, cancel: cancel, respCh: respCh}
-	wrap := func(err error) error {
-		return errors.Wrap(err, fmt.Sprintf("ERR %s %q", method, url))
-	}
-	log.Println("} BEGIN {", method, url)
+// CheckLogin asks the hub whose token it is. The user name is empty once
+// the token has expired, so saved tokens can be checked before use. It is
+// sent without the cookie of SetToken, which the hub would answer for
+// instead.
+func (c *Client) CheckLogin(ctx context.Context, token string) *CheckLoginReq {
+	form := make(url.Values)
+	form.Set("token", token)
+	req := post("check_login.php", form.Encode())
+	req.noCookies = true
+	r := &CheckLoginReq{}
+	c.start(ctx, &r.Req, req, jsonDecoder(&r.Resp))
+	return r
+}
 
-	go func() {
-		defer close(respCh)
-		defer cancel()
-		defer log.Println("} CANCEL {", method, url)
+type LevelsGetResponse struct {
+	Success bool        `json:"success"`
+	Error   string      `json:"error"`
+	Levels  []LevelInfo `json:"levels"`
+}
 
-		req, err := http.NewRequestWithContext(ctx, method, url, nil)
-		if err != nil {
-			respCh <- err
-			return
-		}
+// LevelInfo describes a level in the hub's level lists.
+type LevelInfo struct {
+	LevelID   string  `json:"level_id"`
+	Version   string  `json:"version"`
+	Title     string  `json:"title"`
+	Rating    float64 `json:"rating"`
+	PlayCount string  `json:"play_count"`
+	MinLevel  string  `json:"min_level"`
+	Note      string  `json:"note"`
+	Live      string  `json:"live"`
+	Type      string  `json:"type"`
+	Time      string  `json:"time"`
+	Name      string  `json:"name"`
+	Power     string  `json:"power"`
+	TrialMod  string  `json:"trial_mod"`
+	UserID    string  `json:"user_id"`
+}
 
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			respCh <- err
-			return
-		}
-		defer resp.Body.Close()
+type LevelsGetReq struct {
+	Req
+	Resp LevelsGetResponse
+}
 
-		if resp.StatusCode != http.StatusOK {
-			respCh <- wrap(fmt.Errorf("bad status: %s", resp.Status))
-			return
-		}
+// LevelsGet lists the levels of the logged in user.
+func (c *Client) LevelsGet(ctx context.Context) *LevelsGetReq {
+	r := &LevelsGetReq{}
+	c.start(ctx, &r.Req, get("levels_get.php"), jsonDecoder(&r.Resp))
+	return r
+}
 
-		isJSON := (v != nil)
-		if isJSON {
-			err = json.NewDecoder(resp.Body).Decode(v)
-			if err != nil {
-				respCh <- err
-				return
-			}
-			respCh <- v
-		} else {
-			b, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				respCh <- err
-				return
-			}
-			respCh <- string(b)
-		}
-		log.Println("} SUCCESS {", method, url)
-	}()
+// The ways SearchLevels can match levels.
+const (
+	SearchTitle = "title"
+	SearchUser  = "user"
+)
 
-	return req
-}
+// The orders SearchLevels can list levels in.
+const (
+	OrderPopularity   = "popularity"
+	OrderRating       = "rating"
+	OrderDate         = "date"
+	OrderAlphabetical = "alphabetical"
+)
 
-func Level(id, version string) *Req {
-	uri := fmt.Sprintf("https://pr2hub.com/levels/%s.txt?version=%s", id, version)
-	// return httpReq(nil, "GET", url, nil)
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// query := make(url.Values)
-	var data string
-	// levels are not correctly url escaped so we can't
-	// unmarshal to url.Values
-	return httpReq3(req, stringUnmarshal, &data)
+// SearchLevels finds the public levels whose title or author, depending on
+// mode, matches query. The results come a page at a time, from page 1.
+func (c *Client) SearchLevels(ctx context.Context, mode, query, order string, page int) *LevelsGetReq {
+	form := make(url.Values)
+	form.Set("search_str", query)
+	form.Set("mode", mode)
+	form.Set("order", order)
+	form.Set("dir", "desc")
+	form.Set("page", strconv.Itoa(page))
+	r := &LevelsGetReq{}
+	c.start(ctx, &r.Req, post("search_levels.php", form.Encode()), jsonDecoder(&r.Resp))
+	return r
 }
 
-type Req struct {
-	ctx    context.Context
-	cancel context.CancelFunc
-	respCh <-chan interface{}
-	err    error
-}
+// The level lists that the hub keeps.
+const (
+	ListNewest    = "newest"
+	ListBest      = "best"
+	ListBestToday = "best_today"
+	ListCampaign  = "campaign"
+)
 
-func (r *Req) Done(v interface{}) bool {
-	select {
-	case <-r.ctx.Done():
-		// request was canceled or timed out
-		r.err = r.ctx.Err()
-		return true
-	case resp, notClosed := <-r.respCh:
-		if notClosed {
-			if err, ok := resp.(error); ok {
-				r.err = err
-				return true
-			}
-			// http request succeeded!
-			rv := reflect.ValueOf(v)
-			if rv.Kind() != reflect.Ptr || rv.IsNil() {
-				panic("v should be non-nil pointer")
-			}
-			// *v = *resp
-			if reflect.ValueOf(resp).Kind() == reflect.String {
-				rv.Elem().SetString(resp.(string))
-			} else {
-				rv.Elem().Set(reflect.ValueOf(resp).Elem())
-			}
-			return true
-		}
-		// we already succeeded, but ok
-		return true
-	default:
-		// still not done
-		return false
-	}
+// LevelList gets a page, from 1, of one of the hub's level lists.
+func (c *Client) LevelList(ctx context.Context, list string, page int) *LevelsGetReq {
+	r := &LevelsGetReq{}
+	path := fmt.Sprintf("files/lists/%s/%d", url.PathEscape(list), page)
+	c.start(ctx, &r.Req, get(path), jsonDecoder(&r.Resp))
+	return r
 }
 
-func (r *Req) Cancel() {
-	r.cancel()
+type LevelReq struct {
+	Req
+	// Data is the level in the text format that course.Parse reads.
+	Data string
 }
 
-func (r *Req) Err() error {
-	return r.err
+// Level downloads a version of a level, or the newest if version is empty.
+func (c *Client) Level(ctx context.Context, id, version string) *LevelReq {
+	r := &LevelReq{}
+	path := "levels/" + url.PathEscape(id) + ".txt?" + url.Values{"version": {version}}.Encode()
+	// levels are not correctly url escaped so we can't
+	// unmarshal to url.Values
+	c.start(ctx, &r.Req, get(path), func(body io.Reader) error {
+		b, err := ioutil.ReadAll(body)
+		r.Data = string(b)
+		return err
+	})
+	return r
 }
 
 type jsonResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
 }
-
-type UnmarshalFunc func(r io.Reader, v interface{}) error
-
-var (
-	jsonUnmarshal = func(r io.Reader, v interface{}) error {
-		return json.NewDecoder(r).Decode(v)
-	}
-	stringUnmarshal = func(r io.Reader, v interface{}) error {
-		b, err := ioutil.ReadAll(r)
-		*v.(*string) = string(b)
-		return err
-	}
-	queryUnmarshal = func(r io.Reader, v interface{}) error {
-		b, err := ioutil.ReadAll(r)
-		if err != nil {
-			return err
-		}
-		*v.(*url.Values), err = url.ParseQuery(string(b))
-		return err
-	}
-)
@@ -0,0 +1,440 @@
+package pr2hub_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fourst4r/levedit/pr2hub"
+	"github.com/fourst4r/levedit/pr2hub/pr2hubtest"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+func newHub(t *testing.T) (*pr2hubtest.Hub, *pr2hub.Client) {
+	hub := pr2hubtest.NewHub()
+	hub.AddUser("alice", "hunter2")
+	hub.AddUser("bob", "swordfish")
+	srv := httptest.NewServer(hub)
+	t.Cleanup(srv.Close)
+	return hub, pr2hub.NewClient(srv.URL, nil)
+}
+
+func login(t *testing.T, c *pr2hub.Client, user, pass string) pr2hub.LoginResponse {
+	t.Helper()
+	req := c.Login(context.Background(), user, pass, false)
+	if err := req.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	return req.Resp
+}
+
+func upload(t *testing.T, c *pr2hub.Client, token, title string, overwrite bool) url.Values {
+	t.Helper()
+	val := url.Values{
+		"title": {title},
+		"note":  {"a note"},
+		"data":  {"m4`ffffff`"},
+		"live":  {"0"},
+		"token": {token},
+	}
+	if overwrite {
+		val.Set("overwrite_existing", "1")
+	}
+	req := c.UploadLevel(context.Background(), val.Encode())
+	if err := req.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	return req.Resp
+}
+
+func levels(t *testing.T, c *pr2hub.Client) pr2hub.LevelsGetResponse {
+	t.Helper()
+	req := c.LevelsGet(context.Background())
+	if err := req.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	return req.Resp
+}
+
+func TestLogin(t *testing.T) {
+	_, c := newHub(t)
+	tests := []struct {
+		name, user, pass string
+		success          bool
+	}{
+		{"ok", "alice", "hunter2", true},
+		{"case insensitive user", "Alice", "hunter2", true},
+		{"wrong pass", "alice", "hunter3", false},
+		{"unknown user", "carol", "hunter2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := login(t, c, tt.user, tt.pass)
+			if resp.Success != tt.success {
+				t.Fatalf("Success = %v, want %v (error %q)", resp.Success, tt.success, resp.Error)
+			}
+			if resp.Success && resp.Token == "" {
+				t.Error("no token")
+			}
+			if !resp.Success && resp.Error == "" {
+				t.Error("no error message")
+			}
+		})
+	}
+}
+
+func TestDecodeLogin(t *testing.T) {
+	// Login with a password that needs several AES blocks and padding, and
+	// quotes and a backslash that must be escaped in the JSON
+	pass := `a "password" longer than one block, with a \`
+	hub, c := newHub(t)
+	hub.AddUser("carol", pass)
+	if resp := login(t, c, "carol", pass); !resp.Success {
+		t.Fatalf("login: %q", resp.Error)
+	}
+	if _, err := pr2hub.DecodeLogin("not base64!"); err == nil {
+		t.Error("DecodeLogin() accepted garbage")
+	}
+}
+
+func TestLevelsGetNeedsLogin(t *testing.T) {
+	_, c := newHub(t)
+	if resp := levels(t, c); resp.Success {
+		t.Error("LevelsGet() succeeded without a token")
+	}
+}
+
+func TestCheckLogin(t *testing.T) {
+	hub, c := newHub(t)
+	expired := hub.Token("bob")
+	hub.Expire(expired)
+	tests := []struct {
+		name, token, want string
+	}{
+		{"valid", hub.Token("alice"), "alice"},
+		{"expired", expired, ""},
+		{"unknown", "not a token", ""},
+		{"none", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := c.CheckLogin(context.Background(), tt.token)
+			if err := req.Wait(); err != nil {
+				t.Fatal(err)
+			}
+			if req.Resp.UserName != tt.want {
+				t.Errorf("CheckLogin() user = %q, want %q", req.Resp.UserName, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLoginOtherAccount(t *testing.T) {
+	hub, c := newHub(t)
+	c.SetToken(hub.Token("alice"))
+	bob := hub.Token("bob")
+	hub.Expire(bob)
+	req := c.CheckLogin(context.Background(), bob)
+	if err := req.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if req.Resp.UserName != "" {
+		t.Errorf("CheckLogin(bob's expired token) user = %q while logged in as alice, want none", req.Resp.UserName)
+	}
+	// the client is still logged in as alice
+	if resp := levels(t, c); !resp.Success {
+		t.Errorf("LevelsGet() after CheckLogin() failed: %s", resp.Error)
+	}
+}
+
+// publish uploads a public level.
+func publish(t *testing.T, c *pr2hub.Client, token, title string) {
+	t.Helper()
+	val := url.Values{"title": {title}, "data": {"m4`ffffff`"}, "live": {"1"}, "token": {token}}
+	if err := c.UploadLevel(context.Background(), val.Encode()).Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func titles(t *testing.T, req *pr2hub.LevelsGetReq) []string {
+	t.Helper()
+	if err := req.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !req.Resp.Success {
+		t.Fatal(req.Resp.Error)
+	}
+	titles := []string{}
+	for _, l := range req.Resp.Levels {
+		titles = append(titles, l.Title)
+	}
+	return titles
+}
+
+func TestSearchLevels(t *testing.T) {
+	hub, c := newHub(t)
+	alice, bob := hub.Token("alice"), hub.Token("bob")
+	publish(t, c, alice, "Castle run")
+	publish(t, c, bob, "Bob's castle")
+	publish(t, c, bob, "Ice cave")
+	upload(t, c, alice, "Secret castle", false) // not public
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		req  *pr2hub.LevelsGetReq
+		want []string
+	}{
+		{"title", c.SearchLevels(ctx, pr2hub.SearchTitle, "castle", pr2hub.OrderDate, 1),
+			[]string{"Bob's castle", "Castle run"}},
+		{"alphabetical", c.SearchLevels(ctx, pr2hub.SearchTitle, "castle", pr2hub.OrderAlphabetical, 1),
+			[]string{"Bob's castle", "Castle run"}},
+		{"user", c.SearchLevels(ctx, pr2hub.SearchUser, "Bob", pr2hub.OrderDate, 1),
+			[]string{"Ice cave", "Bob's castle"}},
+		{"past the end", c.SearchLevels(ctx, pr2hub.SearchTitle, "castle", pr2hub.OrderDate, 2), []string{}},
+		{"newest", c.LevelList(ctx, pr2hub.ListNewest, 1), []string{"Ice cave", "Bob's castle", "Castle run"}},
+		{"campaign", c.LevelList(ctx, pr2hub.ListCampaign, 1), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(t, tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelListPages(t *testing.T) {
+	hub, c := newHub(t)
+	token := hub.Token("alice")
+	for i := 0; i < pr2hubtest.PageSize+2; i++ {
+		publish(t, c, token, fmt.Sprint("level ", i))
+	}
+	ctx := context.Background()
+	if got := titles(t, c.LevelList(ctx, pr2hub.ListNewest, 1)); len(got) != pr2hubtest.PageSize {
+		t.Errorf("page 1 has %d levels, want %d", len(got), pr2hubtest.PageSize)
+	}
+	if got := titles(t, c.LevelList(ctx, pr2hub.ListNewest, 2)); !reflect.DeepEqual(got, []string{"level 1", "level 0"}) {
+		t.Errorf("page 2 is %q", got)
+	}
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	_, c := newHub(t)
+	token := login(t, c, "alice", "hunter2").Token
+	c.SetToken(token)
+
+	if resp := upload(t, c, token, "first", false); resp.Get("status") != "ok" {
+		t.Fatalf("upload: %v", resp)
+	}
+	if resp := upload(t, c, token, "first", false); resp.Get("status") != "exists" {
+		t.Fatalf("upload of an existing title: %v", resp)
+	}
+	if resp := upload(t, c, token, "first", true); resp.Get("status") != "ok" {
+		t.Fatalf("overwrite: %v", resp)
+	}
+
+	resp := levels(t, c)
+	if !resp.Success || len(resp.Levels) != 1 {
+		t.Fatalf("LevelsGet() = %+v", resp)
+	}
+	info := resp.Levels[0]
+	if info.Title != "first" || info.Version != "2" || info.Name != "alice" {
+		t.Errorf("level info = %+v", info)
+	}
+
+	for _, version := range []string{"", "1", "2"} {
+		req := c.Level(context.Background(), info.LevelID, version)
+		if err := req.Wait(); err != nil {
+			t.Fatalf("Level(%q, %q): %v", info.LevelID, version, err)
+		}
+		val, err := url.ParseQuery(req.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := version
+		if want == "" {
+			want = "2"
+		}
+		if val.Get("title") != "first" || val.Get("version") != want {
+			t.Errorf("Level(%q, %q) = %v", info.LevelID, version, val)
+		}
+		if val.Get("token") != "" {
+			t.Errorf("Level(%q, %q) leaked the token", info.LevelID, version)
+		}
+	}
+
+	// ids and versions are escaped, so they can't add to the query
+	for _, idv := range [][2]string{{info.LevelID, "3"}, {info.LevelID, "1&version=3"}, {info.LevelID + ".txt?version=1&", "3"}} {
+		if err := c.Level(context.Background(), idv[0], idv[1]).Wait(); err == nil {
+			t.Errorf("Level(%q, %q) of a missing version succeeded", idv[0], idv[1])
+		}
+	}
+}
+
+func TestDeleteLevel(t *testing.T) {
+	hub, c := newHub(t)
+	token := login(t, c, "alice", "hunter2").Token
+	c.SetToken(token)
+	upload(t, c, token, "doomed", false)
+	id := levels(t, c).Levels[0].LevelID
+
+	del := func(token string) pr2hub.DeleteLevelResponse {
+		req := c.DeleteLevel(context.Background(), id, token)
+		if err := req.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		return req.Resp
+	}
+	if resp := del(hub.Token("bob")); resp.Success {
+		t.Error("bob deleted alice's level")
+	}
+	if resp := del(token); !resp.Success {
+		t.Errorf("delete: %q", resp.Error)
+	}
+	if n := len(levels(t, c).Levels); n != 0 {
+		t.Errorf("%d levels left after delete", n)
+	}
+}
+
+func TestClientsAreIndependent(t *testing.T) {
+	hub, c := newHub(t)
+	c.SetToken(hub.Token("alice"))
+	upload(t, c, hub.Token("alice"), "alice's", false)
+
+	other := pr2hub.NewClient(c.BaseURL, nil)
+	other.SetToken(hub.Token("bob"))
+	if n := len(levels(t, other).Levels); n != 0 {
+		t.Errorf("bob sees %d of alice's levels", n)
+	}
+	if n := len(levels(t, c).Levels); n != 1 {
+		t.Errorf("alice sees %d levels, want 1", n)
+	}
+}
+
+// stall returns a client for a hub that never answers until the test ends.
+func stall(t *testing.T) *pr2hub.Client {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stop:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(stop)
+		srv.Close()
+	})
+	return pr2hub.NewClient(srv.URL, nil)
+}
+
+func TestCancel(t *testing.T) {
+	c := stall(t)
+	req := c.LevelsGet(context.Background())
+	if req.Done() {
+		t.Fatal("Done() before the hub answered")
+	}
+	req.Cancel()
+	done := make(chan error)
+	go func() { done <- req.Wait() }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cancel() did not abort the request")
+	}
+	if !req.Done() {
+		t.Error("not Done() after Wait()")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+	}{
+		{"client timeout", 10 * time.Millisecond, func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}},
+		{"caller deadline", 0, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 10*time.Millisecond)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := stall(t)
+			c.Timeout = tt.timeout
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			err := c.Login(ctx, "alice", "hunter2", false).Wait()
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Wait() = %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	hub, c := newHub(t)
+	token := hub.Token("alice")
+	c.SetToken(token)
+	upload(t, c, token, "mine", false)
+
+	// a pending request must not be disturbed by others finishing
+	pending := stall(t).LevelsGet(context.Background())
+	list := c.LevelsGet(context.Background())
+	login := c.Login(context.Background(), "bob", "swordfish", false)
+	if err := list.Wait(); err != nil || len(list.Resp.Levels) != 1 {
+		t.Errorf("LevelsGet() = %+v, %v", list.Resp, err)
+	}
+	if err := login.Wait(); err != nil || !login.Resp.Success {
+		t.Errorf("Login() = %+v, %v", login.Resp, err)
+	}
+	if pending.Done() {
+		t.Error("a stalled request finished")
+	}
+	pending.Cancel()
+}
+
+func TestSetToken(t *testing.T) {
+	hub, c := newHub(t)
+	token := hub.Token("alice")
+
+	// changing accounts while requests are running must be safe
+	var reqs []interface{ Wait() error }
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, c.LevelsGet(context.Background()), c.CheckLogin(context.Background(), token))
+		c.SetToken(token)
+		c.SetToken("")
+	}
+	for _, req := range reqs {
+		if err := req.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.SetToken(token)
+	if resp := levels(t, c); !resp.Success {
+		t.Errorf("LevelsGet() after SetToken() failed: %s", resp.Error)
+	}
+	c.SetToken("")
+	if resp := levels(t, c); resp.Success {
+		t.Error("LevelsGet() succeeded after logging out")
+	}
+}
@@ -0,0 +1,381 @@
+// Package pr2hubtest provides an in-memory pr2hub for tests and for
+// running the editor without network access.
+package pr2hubtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fourst4r/levedit/pr2hub"
+)
+
+// Hub is a fake pr2hub that implements enough of the real one for the
+// editor. It is safe for concurrent use.
+type Hub struct {
+	mu     sync.Mutex
+	users  []*user
+	tokens map[string]*user
+	levels map[int]*level
+	nextID int
+	mux    *http.ServeMux
+}
+
+type user struct {
+	id         int
+	name, pass string
+}
+
+type level struct {
+	id    int
+	owner *user
+	// versions holds every upload of the level, oldest first
+	versions []url.Values
+	times    []time.Time
+}
+
+func (l *level) latest() url.Values {
+	return l.versions[len(l.versions)-1]
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	h := &Hub{
+		tokens: make(map[string]*user),
+		levels: make(map[int]*level),
+		nextID: 1,
+		mux:    http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/login.php", h.login)
+	h.mux.HandleFunc("/check_login.php", h.checkLogin)
+	h.mux.HandleFunc("/levels_get.php", h.levelsGet)
+	h.mux.HandleFunc("/search_levels.php", h.searchLevels)
+	h.mux.HandleFunc("/files/lists/", h.levelList)
+	h.mux.HandleFunc("/levels/", h.levelFile)
+	h.mux.HandleFunc("/upload_level.php", h.uploadLevel)
+	h.mux.HandleFunc("/delete_level.php", h.deleteLevel)
+	return h
+}
+
+// AddUser registers an account that can log in.
+func (h *Hub) AddUser(name, pass string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.users = append(h.users, &user{id: len(h.users) + 1, name: name, pass: pass})
+}
+
+// Token logs name in and returns the token, like a successful login.
+func (h *Hub) Token(name string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, u := range h.users {
+		if strings.EqualFold(u.name, name) {
+			return h.newToken(u)
+		}
+	}
+	return ""
+}
+
+// Expire logs out the session of token, as the hub does after a while.
+func (h *Hub) Expire(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.tokens, token)
+}
+
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Println("pr2hubtest:", r.Method, r.URL)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Hub) newToken(u *user) string {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	token := hex.EncodeToString(b)
+	h.tokens[token] = u
+	return token
+}
+
+// auth returns the user of the token in the form or the token cookie.
+func (h *Hub) auth(r *http.Request) *user {
+	token := r.FormValue("token")
+	if token == "" {
+		if c, err := r.Cookie("token"); err == nil {
+			token = c.Value
+		}
+	}
+	return h.tokens[token]
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, msg string) {
+	writeJSON(w, map[string]interface{}{"success": false, "error": msg})
+}
+
+const errLogin = "Could not find a valid login token. Please log in again."
+
+func (h *Hub) login(w http.ResponseWriter, r *http.Request) {
+	req, err := pr2hub.DecodeLogin(r.FormValue("i"))
+	if err != nil {
+		writeError(w, "Could not decrypt the login data.")
+		return
+	}
+	if req.Build != r.FormValue("build") {
+		writeError(w, "Your version of the game is out of date.")
+		return
+	}
+	for _, u := range h.users {
+		if strings.EqualFold(u.name, req.UserName) && u.pass == req.UserPass {
+			writeJSON(w, pr2hub.LoginResponse{
+				Success: true,
+				UserID:  u.id,
+				Token:   h.newToken(u),
+				Time:    int(time.Now().Unix()),
+			})
+			return
+		}
+	}
+	writeError(w, "That username / password combination was not found.")
+}
+
+func (h *Hub) checkLogin(w http.ResponseWriter, r *http.Request) {
+	resp := pr2hub.CheckLoginResponse{GuildID: 0}
+	// the session cookie wins over the token asked about, so checking
+	// another account while logged in answers for the wrong one
+	if c, err := r.Cookie("token"); err == nil {
+		if u := h.tokens[c.Value]; u != nil {
+			resp.UserName = u.name
+		}
+	} else if u := h.auth(r); u != nil {
+		resp.UserName = u.name
+	}
+	writeJSON(w, resp)
+}
+
+func (h *Hub) levelsGet(w http.ResponseWriter, r *http.Request) {
+	u := h.auth(r)
+	if u == nil {
+		writeError(w, errLogin)
+		return
+	}
+	resp := pr2hub.LevelsGetResponse{Success: true, Levels: []pr2hub.LevelInfo{}}
+	for _, l := range h.levels {
+		if l.owner == u {
+			resp.Levels = append(resp.Levels, h.info(l))
+		}
+	}
+	sort.Slice(resp.Levels, func(i, j int) bool {
+		return resp.Levels[i].Title < resp.Levels[j].Title
+	})
+	writeJSON(w, resp)
+}
+
+// PageSize is how many levels the searches and lists return at once.
+const PageSize = 9
+
+// public returns the published levels, newest first.
+func (h *Hub) public() []*level {
+	var levels []*level
+	for _, l := range h.levels {
+		if l.latest().Get("live") == "1" {
+			levels = append(levels, l)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].id > levels[j].id
+	})
+	return levels
+}
+
+// writePage writes the page, from 1, of levels.
+func (h *Hub) writePage(w http.ResponseWriter, levels []*level, page int) {
+	resp := pr2hub.LevelsGetResponse{Success: true, Levels: []pr2hub.LevelInfo{}}
+	for i := (page - 1) * PageSize; i >= 0 && i < len(levels) && i < page*PageSize; i++ {
+		resp.Levels = append(resp.Levels, h.info(levels[i]))
+	}
+	writeJSON(w, resp)
+}
+
+func (h *Hub) searchLevels(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.FormValue("search_str"))
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil {
+		page = 1
+	}
+	var found []*level
+	for _, l := range h.public() {
+		switch r.FormValue("mode") {
+		case pr2hub.SearchUser:
+			if strings.ToLower(l.owner.name) == query {
+				found = append(found, l)
+			}
+		default:
+			if strings.Contains(strings.ToLower(l.latest().Get("title")), query) {
+				found = append(found, l)
+			}
+		}
+	}
+	// every level is rated the same, so only alphabetical changes the order
+	if r.FormValue("order") == pr2hub.OrderAlphabetical {
+		sort.SliceStable(found, func(i, j int) bool {
+			return found[i].latest().Get("title") < found[j].latest().Get("title")
+		})
+	}
+	h.writePage(w, found, page)
+}
+
+// levelList serves /files/lists/<list>/<page>.
+func (h *Hub) levelList(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/files/lists/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts[0] {
+	case pr2hub.ListNewest, pr2hub.ListBest, pr2hub.ListBestToday:
+		h.writePage(w, h.public(), page)
+	case pr2hub.ListCampaign:
+		// the campaign is made by the game's staff
+		h.writePage(w, nil, page)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Hub) info(l *level) pr2hub.LevelInfo {
+	v := l.latest()
+	return pr2hub.LevelInfo{
+		LevelID:   strconv.Itoa(l.id),
+		Version:   strconv.Itoa(len(l.versions)),
+		Title:     v.Get("title"),
+		Note:      v.Get("note"),
+		Live:      v.Get("live"),
+		MinLevel:  v.Get("min_level"),
+		Type:      v.Get("gameMode"),
+		PlayCount: "0",
+		Time:      strconv.FormatInt(l.times[len(l.times)-1].Unix(), 10),
+		Name:      l.owner.name,
+		Power:     "1",
+		TrialMod:  "0",
+		UserID:    strconv.Itoa(l.owner.id),
+	}
+}
+
+// levelFile serves /levels/<id>.txt?version=<version>.
+func (h *Hub) levelFile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/levels/")
+	id, err := strconv.Atoi(strings.TrimSuffix(name, ".txt"))
+	l, ok := h.levels[id]
+	if err != nil || !ok || !strings.HasSuffix(name, ".txt") {
+		http.NotFound(w, r)
+		return
+	}
+	version := len(l.versions)
+	if v := r.FormValue("version"); v != "" {
+		if version, err = strconv.Atoi(v); err != nil || version < 1 || version > len(l.versions) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	data := make(url.Values)
+	for k, v := range l.versions[version-1] {
+		data[k] = v
+	}
+	data.Set("level_id", strconv.Itoa(l.id))
+	data.Set("version", strconv.Itoa(version))
+	data.Set("user_id", strconv.Itoa(l.owner.id))
+	io.WriteString(w, data.Encode())
+}
+
+func writeQuery(w http.ResponseWriter, status, message string) {
+	io.WriteString(w, url.Values{"status": {status}, "message": {message}}.Encode())
+}
+
+func (h *Hub) uploadLevel(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeQuery(w, "error", err.Error())
+		return
+	}
+	u := h.auth(r)
+	if u == nil {
+		writeQuery(w, "error", errLogin)
+		return
+	}
+	title := r.PostForm.Get("title")
+	if title == "" {
+		writeQuery(w, "error", "You must enter a title.")
+		return
+	}
+
+	data := make(url.Values)
+	for k, v := range r.PostForm {
+		switch k {
+		case "token", "overwrite_existing", "override_existing", "override_banned", "password":
+			// the hub only keeps a hash of the password
+		default:
+			data[k] = v
+		}
+	}
+
+	for _, l := range h.levels {
+		if l.owner == u && l.latest().Get("title") == title {
+			if r.PostForm.Get("overwrite_existing") != "1" {
+				writeQuery(w, "exists", "You have another level with this title.")
+				return
+			}
+			l.versions = append(l.versions, data)
+			l.times = append(l.times, time.Now())
+			writeQuery(w, "ok", "The save was successful.")
+			return
+		}
+	}
+
+	id := h.nextID
+	h.nextID++
+	h.levels[id] = &level{
+		id:       id,
+		owner:    u,
+		versions: []url.Values{data},
+		times:    []time.Time{time.Now()},
+	}
+	writeQuery(w, "ok", "The save was successful.")
+}
+
+func (h *Hub) deleteLevel(w http.ResponseWriter, r *http.Request) {
+	u := h.auth(r)
+	if u == nil {
+		writeError(w, errLogin)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("level_id"))
+	l, ok := h.levels[id]
+	if err != nil || !ok || l.owner != u {
+		writeError(w, fmt.Sprintf("Could not find a level with ID #%s that you own.", r.FormValue("level_id")))
+		return
+	}
+	delete(h.levels, id)
+	writeJSON(w, map[string]interface{}{"success": true})
+}
@@ -0,0 +1,141 @@
+package pr2hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Req is a request to the hub running in the background. The typed
+// requests like LoginReq embed it and hold the response once Done.
+type Req struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	err    error
+}
+
+// Done reports whether the request has finished, without blocking.
+func (r *Req) Done() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the request has finished and returns its error.
+func (r *Req) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// Cancel aborts the request. It is done soon after with a context error.
+func (r *Req) Cancel() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// Err returns why the request failed, once it is done.
+func (r *Req) Err() error {
+	return r.err
+}
+
+// fail finishes r with err without sending anything.
+func (r *Req) fail(err error) {
+	r.err = err
+	r.done = make(chan struct{})
+	close(r.done)
+}
+
+// request is a request to a path of the hub that is yet to be sent.
+type request struct {
+	method, path, body string
+	// noCookies leaves out the cookies of the logged in account
+	noCookies bool
+}
+
+func get(path string) request {
+	return request{method: "GET", path: path}
+}
+
+func post(path, form string) request {
+	return request{method: "POST", path: path, body: form}
+}
+
+func jsonDecoder(v interface{}) func(io.Reader) error {
+	return func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(v)
+	}
+}
+
+// start sends req in the background and decodes the response body with
+// decode before r is done. Cancelling ctx or r aborts the HTTP request.
+func (c *Client) start(ctx context.Context, r *Req, req request, decode func(io.Reader) error) {
+	if c.Timeout > 0 {
+		ctx, r.cancel = context.WithTimeout(ctx, c.Timeout)
+	} else {
+		ctx, r.cancel = context.WithCancel(ctx)
+	}
+	r.done = make(chan struct{})
+
+	var body io.Reader
+	if req.method == "POST" {
+		body = strings.NewReader(req.body)
+	}
+	request, err := http.NewRequestWithContext(ctx, req.method, c.url(req.path), body)
+	if err != nil {
+		r.cancel()
+		r.err = err
+		close(r.done)
+		return
+	}
+	if req.method == "POST" {
+		request.Header.Add("Referer", c.referer())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	method, url := request.Method, request.URL.String()
+	wrap := func(err error) error {
+		return errors.Wrap(err, fmt.Sprintf("ERR %s %q", method, url))
+	}
+	log.Println("} BEGIN {", method, url)
+
+	go func() {
+		defer close(r.done)
+		defer r.cancel()
+
+		hc := c.HTTP
+		if req.noCookies {
+			h := *hc
+			h.Jar = nil
+			hc = &h
+		}
+		resp, err := hc.Do(request)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Println("} CANCEL {", method, url)
+			}
+			r.err = wrap(err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			r.err = wrap(fmt.Errorf("bad status: %s", resp.Status))
+			return
+		}
+		if err := decode(resp.Body); err != nil {
+			r.err = wrap(err)
+			return
+		}
+		log.Println("} SUCCESS {", method, url)
+	}()
+}
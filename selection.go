@@ -0,0 +1,233 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/fourst4r/course"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+type tool int
+
+const (
+	toolPaint tool = iota
+	toolSelect
+	toolFill
+	toolLine
+	toolRect
+	toolFilledRect
+)
+
+var tools = []string{"Paint", "Select", "Fill", "Line", "Rect", "Filled Rect"}
+
+// rect is a rectangle of cells in block units, inclusive on all sides.
+type rect struct{ x0, y0, x1, y1 int }
+
+// rectOf returns the smallest rect that contains the cells a and b.
+func rectOf(a, b course.XY) rect {
+	r := rect{cell(a.X), cell(a.Y), cell(b.X), cell(b.Y)}
+	if r.x0 > r.x1 {
+		r.x0, r.x1 = r.x1, r.x0
+	}
+	if r.y0 > r.y1 {
+		r.y0, r.y1 = r.y1, r.y0
+	}
+	return r
+}
+
+func (r rect) contains(xy course.XY) bool {
+	x, y := cell(xy.X), cell(xy.Y)
+	return x >= r.x0 && x <= r.x1 && y >= r.y0 && y <= r.y1
+}
+
+func (r rect) translate(dx, dy int) rect {
+	return rect{r.x0 + dx, r.y0 + dy, r.x1 + dx, r.y1 + dy}
+}
+
+// origin returns the top left cell of r.
+func (r rect) origin() course.XY {
+	return course.XY{X: r.x0 * tileSize, Y: r.y0 * tileSize}
+}
+
+// cell returns the block unit that the world coordinate v falls in.
+func cell(v int) int {
+	return int(math.Floor(float64(v) / tileSize))
+}
+
+// world returns the bounds of r in world pixels.
+func (r rect) world() (x0, y0, x1, y1 float64) {
+	return float64(r.x0 * tileSize), float64(r.y0 * tileSize),
+		float64((r.x1 + 1) * tileSize), float64((r.y1 + 1) * tileSize)
+}
+
+type selection struct {
+	rect   rect
+	active bool
+	// selecting is set while a new rect is being dragged out from anchor,
+	// moving while the selected blocks are being dragged from anchor.
+	selecting, moving bool
+	anchor, cur       course.XY
+	// moved are the blocks being dragged, copied when the drag started
+	moved clipboard
+}
+
+// offset returns how many cells the selection has been dragged.
+func (s *selection) offset() (dx, dy int) {
+	return (s.cur.X - s.anchor.X) / tileSize, (s.cur.Y - s.anchor.Y) / tileSize
+}
+
+// clipboard holds block stacks keyed by their offset from the top left
+// of the region they were copied from.
+type clipboard map[course.XY][]int
+
+func (e *Editor) updateSelection(xy course.XY) {
+	s := &e.sel
+	s.cur = xy
+	switch {
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		s.anchor = xy
+		if s.active && s.rect.contains(xy) {
+			s.moving = true
+			s.moved = e.copyRegion(s.rect)
+		} else {
+			s.selecting = true
+			s.active = true
+			s.rect = rectOf(xy, xy)
+		}
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight):
+		e.deselect()
+	case s.selecting:
+		s.rect = rectOf(s.anchor, xy)
+	}
+}
+
+// endSelectionDrag finishes the drag started in updateSelection.
+func (e *Editor) endSelectionDrag() {
+	s := &e.sel
+	if s.moving {
+		if dx, dy := s.offset(); dx != 0 || dy != 0 {
+			e.moveSelection(dx, dy)
+		}
+	}
+	s.selecting, s.moving, s.moved = false, false, nil
+}
+
+func (e *Editor) deselect() {
+	e.sel = selection{}
+}
+
+// copyRegion returns the stacks inside r.
+func (e *Editor) copyRegion(r rect) clipboard {
+	clip := make(clipboard)
+	o := r.origin()
+	for xy := range e.Course.Blocks {
+		if r.contains(xy) {
+			if stack := stackAt(e.Course, xy); len(stack) > 0 {
+				clip[course.XY{X: xy.X - o.X, Y: xy.Y - o.Y}] = stack
+			}
+		}
+	}
+	return clip
+}
+
+func (e *Editor) copySelection() {
+	if e.sel.active {
+		e.clipboard = e.copyRegion(e.sel.rect)
+		e.clipboardRect = e.sel.rect.translate(-e.sel.rect.x0, -e.sel.rect.y0)
+	}
+}
+
+func (e *Editor) cutSelection() {
+	e.copySelection()
+	e.deleteSelection()
+}
+
+func (e *Editor) deleteSelection() {
+	if !e.sel.active || e.locked(layerBlocks) {
+		return
+	}
+	ed := newBlocksEdit()
+	o := e.sel.rect.origin()
+	for xy := range e.copyRegion(e.sel.rect) {
+		ed.set(e.Course, course.XY{X: o.X + xy.X, Y: o.Y + xy.Y}, nil)
+	}
+	if !ed.empty() {
+		e.do(ed)
+	}
+}
+
+// paste puts the clipboard down with its top left at xy and selects it.
+func (e *Editor) paste(xy course.XY) {
+	if len(e.clipboard) == 0 || e.locked(layerBlocks) {
+		return
+	}
+	ed := newBlocksEdit()
+	for off, stack := range e.clipboard {
+		ed.set(e.Course, course.XY{X: xy.X + off.X, Y: xy.Y + off.Y}, stack)
+	}
+	e.do(ed)
+	e.sel = selection{
+		active: true,
+		rect:   e.clipboardRect.translate(cell(xy.X), cell(xy.Y)),
+	}
+}
+
+// moveSelection moves the selected stacks by dx, dy cells, replacing
+// whatever was at the destination.
+func (e *Editor) moveSelection(dx, dy int) {
+	if e.locked(layerBlocks) {
+		return
+	}
+	clip := e.copyRegion(e.sel.rect)
+	from := e.sel.rect.origin()
+	e.sel.rect = e.sel.rect.translate(dx, dy)
+	to := e.sel.rect.origin()
+
+	ed := newBlocksEdit()
+	for off := range clip {
+		ed.set(e.Course, course.XY{X: from.X + off.X, Y: from.Y + off.Y}, nil)
+	}
+	for off, stack := range clip {
+		ed.set(e.Course, course.XY{X: to.X + off.X, Y: to.Y + off.Y}, stack)
+	}
+	e.do(ed)
+}
+
+func (e *Editor) drawSelection(screen *ebiten.Image, cam ebiten.GeoM) {
+	s := &e.sel
+	if !s.active {
+		return
+	}
+	r := s.rect
+	if s.moving {
+		dx, dy := s.offset()
+		r = r.translate(dx, dy)
+		o := s.rect.origin()
+		for off, stack := range s.moved {
+			for _, id := range stack {
+				op := &ebiten.DrawImageOptions{}
+				op.ColorM.Translate(0, 0, 0, -.5)
+				op.GeoM.Translate(float64(o.X+off.X+dx*tileSize), float64(o.Y+off.Y+dy*tileSize))
+				op.GeoM.Concat(cam)
+				screen.DrawImage(blockImage(id), op)
+			}
+		}
+	}
+	x0, y0, x1, y1 := r.world()
+	drawWorldRect(screen, cam, x0, y0, x1, y1, color.White)
+}
+
+// drawWorldRect outlines the world space rectangle x0,y0 x1,y1.
+func drawWorldRect(screen *ebiten.Image, cam ebiten.GeoM, x0, y0, x1, y1 float64, clr color.Color) {
+	ax, ay := cam.Apply(x0, y0)
+	bx, by := cam.Apply(x1, y0)
+	cx, cy := cam.Apply(x1, y1)
+	dx, dy := cam.Apply(x0, y1)
+	ebitenutil.DrawLine(screen, ax, ay, bx, by, clr)
+	ebitenutil.DrawLine(screen, bx, by, cx, cy, clr)
+	ebitenutil.DrawLine(screen, cx, cy, dx, dy, clr)
+	ebitenutil.DrawLine(screen, dx, dy, ax, ay, clr)
+}
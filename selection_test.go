@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fourst4r/course"
+)
+
+func Test_rectOf(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b course.XY
+		want rect
+	}{
+		{"single", course.XY{X: 30, Y: 60}, course.XY{X: 30, Y: 60}, rect{1, 2, 1, 2}},
+		{"flipped", course.XY{X: 90, Y: 0}, course.XY{X: -30, Y: -60}, rect{-1, -2, 3, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rectOf(tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("rectOf() = %v, want %v", got, tt.want)
+			}
+			if !got.contains(tt.a) || !got.contains(tt.b) {
+				t.Errorf("rectOf() = %v, does not contain its corners", got)
+			}
+		})
+	}
+}
+
+// courseOf returns a course with a stack of the given blocks on each cell.
+func courseOf(stacks map[course.XY][]int) *course.Course {
+	c := &course.Course{Blocks: make(course.BlockMap)}
+	for xy, ids := range stacks {
+		setStack(c, xy, ids)
+	}
+	return c
+}
+
+// stacksOf returns the non-empty stacks of c.
+func stacksOf(c *course.Course) map[course.XY][]int {
+	m := make(map[course.XY][]int)
+	for xy := range c.Blocks {
+		if stack := stackAt(c, xy); len(stack) > 0 {
+			m[xy] = stack
+		}
+	}
+	return m
+}
+
+func TestSelectionEdits(t *testing.T) {
+	a, b, c := cells(0, 0)[0], cells(1, 0)[0], cells(5, 5)[0]
+	start := map[course.XY][]int{a: {1, 2}, b: {3}, c: {4}}
+	tests := []struct {
+		name string
+		do   func(e *Editor)
+		want map[course.XY][]int
+	}{
+		{"copy", func(e *Editor) {
+			e.copySelection()
+		}, start},
+		{"delete", func(e *Editor) {
+			e.deleteSelection()
+		}, map[course.XY][]int{c: {4}}},
+		{"cut and paste", func(e *Editor) {
+			e.cutSelection()
+			e.paste(cells(4, 5)[0])
+		}, map[course.XY][]int{cells(4, 5)[0]: {1, 2}, c: {3}}},
+		{"copy and paste", func(e *Editor) {
+			e.copySelection()
+			e.paste(cells(0, 2)[0])
+		}, map[course.XY][]int{a: {1, 2}, b: {3}, c: {4}, cells(0, 2)[0]: {1, 2}, cells(1, 2)[0]: {3}}},
+		{"move", func(e *Editor) {
+			e.moveSelection(1, 0)
+		}, map[course.XY][]int{b: {1, 2}, cells(2, 0)[0]: {3}, c: {4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Editor{Course: courseOf(start)}
+			// select the first two cells
+			e.sel = selection{active: true, rect: rectOf(a, b)}
+			tt.do(e)
+			if got := stacksOf(e.Course); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after %s the course is %v, want %v", tt.name, got, tt.want)
+			}
+			for e.history.undo(e.Course) {
+			}
+			if got := stacksOf(e.Course); !reflect.DeepEqual(got, start) {
+				t.Errorf("undoing %s left %v, want %v", tt.name, got, start)
+			}
+		})
+	}
+}
+
+func TestSelectionClipboard(t *testing.T) {
+	a := cells(2, 3)[0]
+	e := &Editor{Course: courseOf(map[course.XY][]int{a: {7, 8}, cells(9, 9)[0]: {1}})}
+	e.sel = selection{active: true, rect: rect{2, 3, 4, 4}}
+	e.copySelection()
+	want := clipboard{{}: {7, 8}}
+	if !reflect.DeepEqual(e.clipboard, want) {
+		t.Errorf("clipboard = %v, want %v", e.clipboard, want)
+	}
+	if want := (rect{0, 0, 2, 1}); e.clipboardRect != want {
+		t.Errorf("clipboardRect = %v, want %v", e.clipboardRect, want)
+	}
+	// pasting selects what was pasted
+	e.paste(cells(10, 10)[0])
+	if want := (rect{10, 10, 12, 11}); !e.sel.active || e.sel.rect != want {
+		t.Errorf("after paste the selection is %+v, want %v", e.sel, want)
+	}
+	e.undo()
+	e.redo()
+	if got := stackAt(e.Course, cells(10, 10)[0]); !reflect.DeepEqual(got, []int{7, 8}) {
+		t.Errorf("after undo and redo the paste is %v, want [7 8]", got)
+	}
+}
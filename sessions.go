@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/fourst4r/levedit/pr2hub"
+)
+
+// sessionCheck asks the hub whether a saved login still works.
+type sessionCheck struct {
+	user, token string
+	req         *pr2hub.CheckLoginReq
+}
+
+// sessionValid reports whether the hub still knows the token as user's.
+func sessionValid(resp pr2hub.CheckLoginResponse, user string) bool {
+	return resp.UserName != "" && strings.EqualFold(resp.UserName, user)
+}
+
+// accLabel names an account in the account combo.
+func accLabel(acc Acc) string {
+	switch {
+	case acc.expired:
+		return acc.User + " (expired)"
+	case acc.Token == "":
+		return acc.User + " (locked)"
+	}
+	return acc.User
+}
+
+// checkSessions checks every saved login with the hub in the background.
+func (e *Editor) checkSessions() {
+	for _, acc := range e.config.Accs {
+		if acc.Token == "" {
+			continue
+		}
+		e.sessionChecks = append(e.sessionChecks, &sessionCheck{
+			user:  acc.User,
+			token: acc.Token,
+			req:   e.hub.CheckLogin(context.Background(), acc.Token),
+		})
+	}
+}
+
+// pollSessions flags the logins the hub turned down since the last frame.
+func (e *Editor) pollSessions() {
+	checks := e.sessionChecks[:0]
+	for _, c := range e.sessionChecks {
+		if !c.req.Done() {
+			checks = append(checks, c)
+			continue
+		}
+		if err := c.req.Err(); err != nil {
+			// the hub may just be down, which says nothing about the token
+			log.Println(err)
+		} else if !sessionValid(c.req.Resp, c.user) {
+			e.sessionExpired(c.user, c.token)
+		}
+	}
+	e.sessionChecks = checks
+}
+
+// sessionExpired flags the account that logged in with token, and asks
+// for its password again if it is the selected one.
+func (e *Editor) sessionExpired(user, token string) {
+	log.Printf("The session of %s has expired\n", user)
+	for i := range e.config.Accs {
+		acc := &e.config.Accs[i]
+		if acc.User == user && acc.Token == token {
+			acc.expired = true
+			if i == e.config.selectedAcc() {
+				e.promptLogin(*acc)
+			}
+		}
+	}
+}
+
+// loginPrompt tells why acc has to log in again.
+func loginPrompt(acc Acc) string {
+	if acc.Token == "" && !acc.expired {
+		return fmt.Sprintf("The login of %s is locked, unlock the saved logins or log in again.", acc.User)
+	}
+	return fmt.Sprintf("The session of %s has expired, please log in again.", acc.User)
+}
+
+// promptLogin opens the login popup for acc, which can't be used as it is.
+func (e *Editor) promptLogin(acc Acc) {
+	e.loginuser, e.loginpass = acc.User, ""
+	e.loginstatus = loginPrompt(acc)
+	e.openLogin = true
+}
+
+// addAcc saves a login, replacing an earlier one of the same user, and
+// selects it.
+func (e *Editor) addAcc(acc Acc) {
+	for i := range e.config.Accs {
+		if strings.EqualFold(e.config.Accs[i].User, acc.User) {
+			e.config.Accs[i] = acc
+			e.updateSelectedAcc(i)
+			return
+		}
+	}
+	e.config.Accs = append(e.config.Accs, acc)
+	e.updateSelectedAcc(len(e.config.Accs) - 1)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fourst4r/levedit/pr2hub"
+)
+
+func Test_sessionValid(t *testing.T) {
+	tests := []struct {
+		name, user, hubUser string
+		want                bool
+	}{
+		{"same", "alice", "alice", true},
+		{"case", "Alice", "alice", true},
+		{"expired", "alice", "", false},
+		{"someone else", "alice", "bob", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := pr2hub.CheckLoginResponse{UserName: tt.hubUser}
+			if got := sessionValid(resp, tt.user); got != tt.want {
+				t.Errorf("sessionValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_accLabel(t *testing.T) {
+	tests := []struct {
+		acc  Acc
+		want string
+	}{
+		{Acc{User: "alice", Token: "t"}, "alice"},
+		{Acc{User: "alice", Token: "t", expired: true}, "alice (expired)"},
+		{Acc{User: "alice"}, "alice (locked)"},
+	}
+	for _, tt := range tests {
+		if got := accLabel(tt.acc); got != tt.want {
+			t.Errorf("accLabel(%+v) = %q, want %q", tt.acc, got, tt.want)
+		}
+	}
+}
+
+func Test_loginPrompt(t *testing.T) {
+	tests := []struct {
+		acc  Acc
+		want string
+	}{
+		{Acc{User: "alice", Token: "t", expired: true}, "The session of alice has expired, please log in again."},
+		{Acc{User: "alice"}, "The login of alice is locked, unlock the saved logins or log in again."},
+	}
+	for _, tt := range tests {
+		if got := loginPrompt(tt.acc); got != tt.want {
+			t.Errorf("loginPrompt(%+v) = %q, want %q", tt.acc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/fourst4r/levedit/level"
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+// maxRank is the highest rank a level can require.
+const maxRank = 100
+
+// current returns the course with its settings and art, as it is saved.
+func (e *Editor) current() *level.Level {
+	return &level.Level{Course: e.Course, Settings: e.opts, Art: e.art}
+}
+
+// settingsChanged marks the course unsaved, since settings aren't undoable
+// edits, and makes the next autosave include them.
+func (e *Editor) settingsChanged() {
+	e.history.changed()
+}
+
+func (e *Editor) settingsTab() {
+	if e.readOnly {
+		imgui.Text("This level is open read-only.")
+		return
+	}
+	s := &e.opts
+	changed := false
+
+	song := "#?"
+	if s.Song >= 0 && s.Song < len(songs) {
+		song = songs[s.Song]
+	}
+	if imgui.BeginCombo("Music", song) {
+		for id, name := range songs {
+			if imgui.Selectable(name) {
+				s.Song = id
+				changed = true
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	if imgui.BeginCombo("Mode", strings.Title(s.Mode)) {
+		for _, mode := range level.Modes {
+			if imgui.Selectable(strings.Title(mode)) {
+				s.Mode = mode
+				changed = true
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	minRank := int32(s.MinRank)
+	if imgui.SliderInt("Min Rank", &minRank, 0, maxRank) {
+		s.MinRank = int(minRank)
+		changed = true
+	}
+	gravity := float32(s.Gravity)
+	if imgui.SliderFloat("Gravity", &gravity, 0.1, 3) {
+		s.Gravity = float64(gravity)
+		changed = true
+	}
+	time := int32(s.Time)
+	if imgui.InputInt("Time (s)", &time) {
+		if time < 0 {
+			time = 0
+		}
+		s.Time = int(time)
+		changed = true
+	}
+	cowboy := int32(s.CowboyChance)
+	if imgui.SliderInt("Cowboy %", &cowboy, 0, 100) {
+		s.CowboyChance = int(cowboy)
+		changed = true
+	}
+	if imgui.InputTextV("Password", &s.Pass, imgui.InputTextFlagsPassword, nil) {
+		changed = true
+	}
+	if s.HasPass && s.Pass == "" {
+		imgui.Text("This level has a password, enter it again to keep it.")
+	}
+
+	if imgui.CollapsingHeader("Items") {
+		for i, name := range level.Items {
+			if imgui.Checkbox(name, &s.Items[i]) {
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		e.settingsChanged()
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"github.com/fourst4r/course"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// maxFill stops a fill that would run away into empty space.
+const maxFill = 10000
+
+// lineCells returns the cells on the line from a to b, using Bresenham's
+// algorithm over the grid.
+func lineCells(a, b course.XY) []course.XY {
+	x0, y0, x1, y1 := cell(a.X), cell(a.Y), cell(b.X), cell(b.Y)
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	var cells []course.XY
+	for err := dx + dy; ; {
+		cells = append(cells, course.XY{X: x0 * tileSize, Y: y0 * tileSize})
+		if x0 == x1 && y0 == y1 {
+			return cells
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// rectCells returns the cells of r, or only its border unless filled.
+func rectCells(r rect, filled bool) []course.XY {
+	var cells []course.XY
+	for y := r.y0; y <= r.y1; y++ {
+		for x := r.x0; x <= r.x1; x++ {
+			if filled || x == r.x0 || x == r.x1 || y == r.y0 || y == r.y1 {
+				cells = append(cells, course.XY{X: x * tileSize, Y: y * tileSize})
+			}
+		}
+	}
+	return cells
+}
+
+// fillCells returns the empty cells connected to start, without crossing
+// occupied cells or leaving bounds. It gives up after maxFill cells and
+// returns nil and tooLarge.
+func fillCells(start course.XY, bounds rect, occupied func(course.XY) bool) (cells []course.XY, tooLarge bool) {
+	if !bounds.contains(start) || occupied(start) {
+		return nil, false
+	}
+	seen := map[course.XY]bool{start: true}
+	queue := []course.XY{start}
+	for i := 0; i < len(queue); i++ {
+		if len(queue) > maxFill {
+			return nil, true
+		}
+		xy := queue[i]
+		for _, d := range [...]course.XY{{X: tileSize}, {X: -tileSize}, {Y: tileSize}, {Y: -tileSize}} {
+			next := course.XY{X: xy.X + d.X, Y: xy.Y + d.Y}
+			if !seen[next] && bounds.contains(next) && !occupied(next) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return queue, false
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// shape is a line or rect being dragged out with the mouse.
+type shape struct {
+	active      bool
+	erase       bool
+	anchor, cur course.XY
+	fill        fillPreview
+}
+
+// fillPreview caches the cells of the fill tool, which are costly to find,
+// with what they were found for.
+type fillPreview struct {
+	key      fillKey
+	cells    []course.XY
+	tooLarge bool
+}
+
+// fillKey is what a fill depends on: the cell, the course and its changes,
+// and the selection that bounds it.
+type fillKey struct {
+	at       course.XY
+	course   *course.Course
+	changes  int
+	sel      rect
+	selected bool
+}
+
+// fillPreview returns the cells that a fill at the cursor would change,
+// finding them again only when the course or selection changed.
+func (e *Editor) fillPreview() *fillPreview {
+	f := &e.shape.fill
+	key := fillKey{e.shape.cur, e.Course, e.history.changes, e.sel.rect, e.sel.active}
+	if f.key != key {
+		f.key = key
+		f.cells, f.tooLarge = fillCells(e.shape.cur, e.fillBounds(), e.occupied)
+	}
+	return f
+}
+
+// fillBounds is the selection, or else the blocks of the course and a
+// cell around them, so that fills stop at the edge of the level.
+func (e *Editor) fillBounds() rect {
+	if e.sel.active {
+		return e.sel.rect
+	}
+	var r rect
+	first := true
+	for xy, stack := range e.Course.Blocks {
+		if len(stack) == 0 {
+			continue
+		}
+		x, y := cell(xy.X), cell(xy.Y)
+		if first {
+			r, first = rect{x, y, x, y}, false
+			continue
+		}
+		r.x0, r.x1 = min(r.x0, x), max(r.x1, x)
+		r.y0, r.y1 = min(r.y0, y), max(r.y1, y)
+	}
+	return rect{r.x0 - 1, r.y0 - 1, r.x1 + 1, r.y1 + 1}
+}
+
+func (e *Editor) occupied(xy course.XY) bool {
+	_, ok := e.Course.Blocks.Peek(xy.X, xy.Y)
+	return ok
+}
+
+// shapeCells returns the cells that the current tool would change.
+func (e *Editor) shapeCells() []course.XY {
+	s := &e.shape
+	switch e.tool {
+	case toolFill:
+		return e.fillPreview().cells
+	case toolLine:
+		if s.active {
+			return lineCells(s.anchor, s.cur)
+		}
+	case toolRect, toolFilledRect:
+		if s.active {
+			return rectCells(rectOf(s.anchor, s.cur), e.tool == toolFilledRect)
+		}
+	}
+	return []course.XY{s.cur}
+}
+
+func (e *Editor) updateShape(xy course.XY) {
+	s := &e.shape
+	s.cur = xy
+	if e.locked(layerBlocks) {
+		return
+	}
+	lmb := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+	rmb := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight)
+	switch {
+	case e.tool == toolFill:
+		if lmb {
+			e.placeCells(e.shapeCells(), false)
+		}
+	case lmb || rmb:
+		*s = shape{active: true, erase: rmb, anchor: xy, cur: xy}
+	}
+}
+
+// endShape places or erases the line or rect that was dragged out.
+func (e *Editor) endShape() {
+	if !e.shape.active {
+		return
+	}
+	cells := e.shapeCells()
+	e.shape.active = false
+	if !e.locked(layerBlocks) {
+		e.placeCells(cells, e.shape.erase)
+	}
+}
+
+// placeCells puts the selected block in the empty cells, or removes the
+// top block of each cell if erase is set.
+func (e *Editor) placeCells(cells []course.XY, erase bool) {
+	ed := newBlocksEdit()
+	for _, xy := range cells {
+		stack := stackAt(e.Course, xy)
+		switch {
+		case erase && len(stack) > 0:
+			ed.set(e.Course, xy, stack[:len(stack)-1])
+		case !erase && len(stack) == 0:
+			ed.set(e.Course, xy, []int{int(e.block)})
+		}
+	}
+	if !ed.empty() {
+		e.do(ed)
+	}
+}
+
+// drawShape previews the cells the current tool would change.
+func (e *Editor) drawShape(screen *ebiten.Image, cam ebiten.GeoM) {
+	if e.locked(layerBlocks) {
+		return
+	}
+	for _, xy := range e.shapeCells() {
+		op := &ebiten.DrawImageOptions{}
+		if e.shape.active && e.shape.erase {
+			op.ColorM.Scale(1, .3, .3, 1)
+		}
+		op.ColorM.Translate(0, 0, 0, -.5)
+		op.GeoM.Translate(xytof(xy))
+		op.GeoM.Concat(cam)
+		screen.DrawImage(blockImage(int(e.block)), op)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
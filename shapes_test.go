@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fourst4r/course"
+)
+
+// cells converts block unit coordinates to world cells.
+func cells(xy ...int) []course.XY {
+	var c []course.XY
+	for i := 0; i+1 < len(xy); i += 2 {
+		c = append(c, course.XY{X: xy[i] * tileSize, Y: xy[i+1] * tileSize})
+	}
+	return c
+}
+
+func Test_lineCells(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b course.XY
+		want []course.XY
+	}{
+		{"point", cells(1, 1)[0], cells(1, 1)[0], cells(1, 1)},
+		{"horizontal", cells(0, 0)[0], cells(3, 0)[0], cells(0, 0, 1, 0, 2, 0, 3, 0)},
+		{"backwards", cells(0, 2)[0], cells(0, 0)[0], cells(0, 2, 0, 1, 0, 0)},
+		{"diagonal", cells(0, 0)[0], cells(2, 2)[0], cells(0, 0, 1, 1, 2, 2)},
+		{"shallow", cells(0, 0)[0], cells(4, 1)[0], cells(0, 0, 1, 0, 2, 1, 3, 1, 4, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineCells(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lineCells() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rectCells(t *testing.T) {
+	r := rect{0, 0, 2, 2}
+	if got := len(rectCells(r, true)); got != 9 {
+		t.Errorf("filled rect has %d cells, want 9", got)
+	}
+	hollow := rectCells(r, false)
+	if len(hollow) != 8 {
+		t.Errorf("hollow rect has %d cells, want 8", len(hollow))
+	}
+	for _, xy := range hollow {
+		if xy == cells(1, 1)[0] {
+			t.Error("hollow rect contains its center")
+		}
+	}
+}
+
+func Test_fillCells(t *testing.T) {
+	// a 3x3 room with a wall around it and a gap at the top
+	wall := make(map[course.XY]bool)
+	for _, xy := range rectCells(rect{0, 0, 4, 4}, false) {
+		wall[xy] = true
+	}
+	occupied := func(xy course.XY) bool { return wall[xy] }
+
+	if got, _ := fillCells(cells(2, 2)[0], rect{-1, -1, 5, 5}, occupied); len(got) != 9 {
+		t.Errorf("fill of the room = %d cells, want 9", len(got))
+	}
+	if got, tooLarge := fillCells(cells(0, 0)[0], rect{-1, -1, 5, 5}, occupied); got != nil || tooLarge {
+		t.Errorf("fill of a wall = %v, %v, want nil", got, tooLarge)
+	}
+
+	delete(wall, cells(2, 0)[0])
+	if got, _ := fillCells(cells(2, 2)[0], rect{-1, -1, 5, 5}, occupied); len(got) != 9+1+24 {
+		t.Errorf("fill through the gap = %d cells, want %d", len(got), 9+1+24)
+	}
+	if got, _ := fillCells(cells(2, 2)[0], rect{1, 1, 3, 3}, occupied); len(got) != 9 {
+		t.Errorf("fill inside bounds = %d cells, want 9", len(got))
+	}
+	if got, tooLarge := fillCells(cells(0, 0)[0], rect{-10000, -10000, 10000, 10000}, func(course.XY) bool { return false }); got != nil || !tooLarge {
+		t.Errorf("unbounded fill = %d cells, too large %v, want nil and too large", len(got), tooLarge)
+	}
+}
+
+func TestFillPreview(t *testing.T) {
+	e := &Editor{Course: courseOf(map[course.XY][]int{cells(0, 0)[0]: {1}, cells(2, 2)[0]: {1}})}
+	e.tool, e.block = toolFill, 1
+	e.shape.cur = cells(1, 1)[0]
+	if got := len(e.shapeCells()); got != 25-2 {
+		t.Fatalf("fill = %d cells, want %d", got, 25-2)
+	}
+
+	// the fill is kept until the course is edited
+	setStack(e.Course, cells(1, 0)[0], []int{1})
+	if got := len(e.shapeCells()); got != 25-2 {
+		t.Errorf("fill after an unrecorded change = %d cells, want the cached %d", got, 25-2)
+	}
+	e.placeCells(cells(0, 1), false)
+	if got := len(e.shapeCells()); got != 25-4 {
+		t.Errorf("fill after an edit = %d cells, want %d", got, 25-4)
+	}
+
+	e.sel = selection{active: true, rect: rect{1, 1, 2, 2}}
+	if got := len(e.shapeCells()); got != 4-1 {
+		t.Errorf("fill of the selection = %d cells, want %d", got, 4-1)
+	}
+	e.sel.rect = rect{-200, -200, 200, 200}
+	if f := e.fillPreview(); f.cells != nil || !f.tooLarge {
+		t.Errorf("fill of a large selection = %d cells, too large %v, want nil and too large", len(f.cells), f.tooLarge)
+	}
+}
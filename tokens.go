@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	keySize = 32
+	// kdfRounds makes guessing a passphrase from a stolen config slow.
+	kdfRounds = 100000
+	// passphraseEnv holds the passphrase that protects the tokens, if the
+	// user picked one over the key file.
+	passphraseEnv = "LEVEDIT_PASSPHRASE"
+)
+
+var errBadToken = errors.New("token can't be decrypted with this key")
+
+// vault encrypts the login tokens saved in the config.
+type vault struct {
+	aead cipher.AEAD
+}
+
+func newVault(key []byte) (*vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &vault{aead: aead}, nil
+}
+
+// seal encrypts token under a fresh nonce, which it is prefixed with.
+func (v *vault) seal(token string) []byte {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return v.aead.Seal(nonce, nonce, []byte(token), nil)
+}
+
+func (v *vault) open(sealed []byte) (string, error) {
+	n := v.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errBadToken
+	}
+	plain, err := v.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", errBadToken
+	}
+	return string(plain), nil
+}
+
+// passphraseKey derives a key from pass and the salt in the config.
+func passphraseKey(pass string, salt []byte) []byte {
+	return pbkdf2(pass, salt, kdfRounds)
+}
+
+// pbkdf2 is PBKDF2-HMAC-SHA256 for keys of a single block.
+func pbkdf2(pass string, salt []byte, rounds int) []byte {
+	prf := hmac.New(sha256.New, []byte(pass))
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < rounds; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key[:keySize]
+}
+
+func newSalt() []byte {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	return salt
+}
+
+func keyFilePath() string {
+	return filepath.Join(configDir, "key")
+}
+
+// loadKeyFile reads the key at path, making a random one the first time.
+// Whoever can read the file can read the tokens, so it's only readable
+// by the user.
+func loadKeyFile(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err == nil {
+		key := make([]byte, keySize)
+		_, err = rand.Read(key)
+		if err == nil {
+			_, err = f.Write(key)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		return key, nil
+	}
+	if !os.IsExist(err) {
+		return nil, err
+	}
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, errors.New("key file " + path + " is damaged")
+	}
+	return key, nil
+}
+
+// redactor hides the secrets it was given from what is written through it.
+type redactor struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets []string
+}
+
+// logRedactor keeps tokens out of the log, which ends up in bug reports.
+var logRedactor = &redactor{w: os.Stderr}
+
+func init() {
+	log.SetOutput(logRedactor)
+}
+
+func (r *redactor) add(secret string) {
+	if secret == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.secrets {
+		if s == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, secret)
+}
+
+func (r *redactor) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := p
+	for _, s := range r.secrets {
+		if bytes.Contains(out, []byte(s)) {
+			out = []byte(strings.Replace(string(out), s, "[redacted]", -1))
+		}
+	}
+	if _, err := r.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redact keeps secret out of the log from now on.
+func redact(secret string) {
+	logRedactor.add(secret)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_pbkdf2(t *testing.T) {
+	// from RFC 7914, section 11
+	tests := []struct {
+		pass, salt string
+		rounds     int
+		want       string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2(tt.pass, []byte(tt.salt), tt.rounds))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", tt.pass, tt.salt, tt.rounds, got, tt.want)
+		}
+	}
+}
+
+func Test_vault(t *testing.T) {
+	v := testVault(t, 1)
+	a, b := v.seal("token"), v.seal("token")
+	if bytes.Equal(a, b) {
+		t.Error("seal() gave the same ciphertext twice")
+	}
+	if bytes.Contains(a, []byte("token")) {
+		t.Error("seal() left the token readable")
+	}
+	if got, err := v.open(a); err != nil || got != "token" {
+		t.Errorf("open() = %q, %v, want token", got, err)
+	}
+	if _, err := testVault(t, 2).open(a); err == nil {
+		t.Error("open() with another key succeeded")
+	}
+	a[len(a)-1] ^= 1
+	if _, err := v.open(a); err == nil {
+		t.Error("open() of a tampered token succeeded")
+	}
+}
+
+func Test_loadKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "levedit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key")
+
+	first, err := loadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := loadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != keySize || !bytes.Equal(first, again) {
+		t.Errorf("loadKeyFile() made %x and then read %x", first, again)
+	}
+	if err := ioutil.WriteFile(path, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKeyFile(path); err == nil {
+		t.Error("loadKeyFile() of a damaged key succeeded")
+	}
+}
+
+func Test_redactor(t *testing.T) {
+	var buf bytes.Buffer
+	r := &redactor{w: &buf}
+	r.add("abc123")
+	r.add("")
+	msg := "Logged in with abc123, again abc123\n"
+	if n, err := r.Write([]byte(msg)); n != len(msg) || err != nil {
+		t.Errorf("Write() = %d, %v, want %d, nil", n, err, len(msg))
+	}
+	if want := "Logged in with [redacted], again [redacted]\n"; buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+}
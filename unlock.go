@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/inkyblackness/imgui-go/v2"
+)
+
+const PopupUnlock = "Unlock logins##PopupUnlock"
+
+// unlockConfig decrypts the saved tokens, with the passphrase in
+// $LEVEDIT_PASSPHRASE if there is one and the key file otherwise. Setting
+// the passphrase moves the tokens from the key file over to it.
+func (e *Editor) unlockConfig() {
+	c := e.config
+	pass := os.Getenv(passphraseEnv)
+	if c.Salt != nil {
+		if pass == "" || e.unlockWith(pass) != nil {
+			e.openUnlock = true
+		}
+		return
+	}
+
+	key, err := loadKeyFile(keyFilePath())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	v, err := newVault(key)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	save := c.plaintext()
+	if err := c.unlock(v); err != nil {
+		// the key file was lost, so those logins are too
+		log.Println("Some saved logins can't be decrypted and must log in again:", err)
+		for i := range c.Accs {
+			if c.Accs[i].Token == "" {
+				c.Accs[i].Sealed = nil
+			}
+		}
+		c.vault = v
+		save = true
+	}
+	if pass != "" {
+		c.Salt = newSalt()
+		c.vault, _ = newVault(passphraseKey(pass, c.Salt))
+		save = true
+	}
+	if save {
+		if err := c.Save(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (e *Editor) unlockWith(pass string) error {
+	v, err := newVault(passphraseKey(pass, e.config.Salt))
+	if err != nil {
+		return err
+	}
+	return e.config.unlock(v)
+}
+
+// unlockPopup asks for the passphrase that protects the saved logins.
+func (e *Editor) unlockPopup() {
+	if e.openUnlock {
+		e.openUnlock = false
+		imgui.OpenPopup(PopupUnlock)
+	}
+	if imgui.BeginPopupModalV(PopupUnlock, nil, imgui.WindowFlagsAlwaysAutoResize) {
+		imgui.Text("The saved logins are protected by a passphrase.")
+		imgui.InputTextV("passphrase", &e.unlockpass, imgui.InputTextFlagsPassword, nil)
+		if e.unlockstatus != "" {
+			imgui.Text(e.unlockstatus)
+		}
+		if imgui.Button("Unlock") {
+			if err := e.unlockWith(e.unlockpass); err != nil {
+				e.unlockstatus = "Wrong passphrase"
+			} else {
+				e.unlockstatus = ""
+				e.loadSelectedAcc()
+				e.openLogin, e.loginAfterUnlock = e.loginAfterUnlock, false
+				imgui.CloseCurrentPopup()
+			}
+			e.unlockpass = ""
+		}
+		imgui.SameLine()
+		if imgui.Button("Skip") {
+			// the logins stay locked until the next launch
+			e.unlockpass, e.unlockstatus = "", ""
+			e.loginAfterUnlock = false
+			imgui.CloseCurrentPopup()
+		}
+		imgui.EndPopup()
+	}
+}
+
+// lockedLogin fills the login popup while the saved logins are locked,
+// since a new login couldn't be encrypted and would be lost.
+func (e *Editor) lockedLogin() {
+	if e.config.Salt == nil {
+		imgui.Text("Logins can't be saved, the key file couldn't be read.")
+	} else {
+		imgui.Text("Unlock the saved logins to log in.")
+		if imgui.Button("Unlock") {
+			e.openUnlock, e.loginAfterUnlock = true, true
+			imgui.CloseCurrentPopup()
+		}
+		imgui.SameLine()
+	}
+	if imgui.Button("Cancel") {
+		imgui.CloseCurrentPopup()
+	}
+}
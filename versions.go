@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+	"github.com/fourst4r/levedit/pr2hub"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/inkyblackness/imgui-go/v2"
+	"golang.org/x/image/colornames"
+)
+
+// previewTexture is the imgui texture ID of the version preview.
+const previewTexture = imgui.TextureID(1001)
+
+// origin is the level on the hub that the course was downloaded from.
+type origin struct {
+	levelID string
+	// version is the one that was downloaded and latest the newest one
+	// the hub had at the time.
+	version, latest int
+}
+
+// setOrigin records that the course was downloaded as the level in info.
+func (e *Editor) setOrigin(info pr2hub.LevelInfo) {
+	v, _ := strconv.Atoi(info.Version)
+	if e.origin.levelID != info.LevelID {
+		e.versions.reset()
+	}
+	e.origin = origin{levelID: info.LevelID, version: v, latest: v}
+}
+
+// versions is the state of the versions window.
+type versions struct {
+	selected int
+	// data holds the versions downloaded so far, and levels them parsed
+	data   map[int]string
+	levels map[int]*level.Level
+	req    *pr2hub.LevelReq
+	reqFor int
+	err    string
+	// diff compares the selected version to diffCourse, as it was after
+	// diffAt changes
+	diff       level.Diff
+	diffOf     int
+	diffCourse *course.Course
+	diffAt     int
+	showDiff   bool
+	preview    *ebiten.Image
+	// previewOf is the version shown in preview
+	previewOf int
+}
+
+// reset forgets the versions of the previous level.
+func (vs *versions) reset() {
+	if vs.req != nil {
+		vs.req.Cancel()
+	}
+	if vs.preview != nil {
+		vs.preview.Dispose()
+	}
+	*vs = versions{showDiff: vs.showDiff}
+}
+
+// fetchVersion downloads the version v of the level, if it isn't already.
+func (e *Editor) fetchVersion(v int) {
+	vs := &e.versions
+	if _, ok := vs.data[v]; ok || (vs.req != nil && vs.reqFor == v) {
+		return
+	}
+	if vs.req != nil {
+		vs.req.Cancel()
+	}
+	vs.req = e.hub.Level(context.Background(), e.origin.levelID, strconv.Itoa(v))
+	vs.reqFor = v
+	vs.err = ""
+}
+
+// pollVersion keeps the version that finished downloading.
+func (e *Editor) pollVersion() {
+	vs := &e.versions
+	if vs.req == nil || !vs.req.Done() {
+		return
+	}
+	req, v := vs.req, vs.reqFor
+	vs.req = nil
+	if err := req.Err(); err != nil {
+		log.Println(err)
+		vs.err = err.Error()
+		return
+	}
+	l, err := level.Parse(req.Data)
+	if err != nil {
+		log.Println(err)
+		vs.err = err.Error()
+		return
+	}
+	if vs.data == nil {
+		vs.data = make(map[int]string)
+		vs.levels = make(map[int]*level.Level)
+	}
+	vs.data[v], vs.levels[v] = req.Data, l
+}
+
+// selectedVersion returns the selected version if it was downloaded.
+func (e *Editor) selectedVersion() (*level.Level, bool) {
+	l, ok := e.versions.levels[e.versions.selected]
+	return l, ok
+}
+
+// versionDiff compares the selected version to the course, again only
+// once either changed. A stroke counts as a change once it ends.
+func (e *Editor) versionDiff() (level.Diff, bool) {
+	vs := &e.versions
+	l, ok := e.selectedVersion()
+	if !ok {
+		return level.Diff{}, false
+	}
+	if vs.diffOf != vs.selected || vs.diffCourse != e.Course || vs.diffAt != e.history.changes {
+		vs.diff = level.Compare(l, e.current())
+		vs.diffOf, vs.diffCourse, vs.diffAt = vs.selected, e.Course, e.history.changes
+	}
+	return vs.diff, true
+}
+
+// restoreVersion replaces the course with the selected version as an
+// edit that can be undone. It is saved as a new version like any other
+// change.
+func (e *Editor) restoreVersion() {
+	l, ok := e.selectedVersion()
+	if !ok {
+		return
+	}
+	e.do(e.newRestoreEdit(l, e.versions.selected))
+}
+
+// restoreEdit replaces the course, its settings and art with a version of
+// the level. The settings and art live in the editor rather than the
+// course, like with artEdit.
+type restoreEdit struct {
+	blocks          *blocksEdit
+	bg              bgEdit
+	title, note     textEdit
+	opts            *level.Settings
+	beforeOpts      level.Settings
+	afterOpts       level.Settings
+	art             *[level.NumArtLayers]level.Art
+	beforeArt       [level.NumArtLayers]level.Art
+	afterArt        [level.NumArtLayers]level.Art
+	version         *int
+	beforeV, afterV int
+}
+
+// newRestoreEdit returns the edit that restores l, which is version v of
+// the level.
+func (e *Editor) newRestoreEdit(l *level.Level, v int) *restoreEdit {
+	c := e.Course
+	blocks := newBlocksEdit()
+	restore := func(xy course.XY) {
+		before, after := stackAt(c, xy), stackAt(l.Course, xy)
+		if !reflect.DeepEqual(before, after) {
+			blocks.before[xy], blocks.after[xy] = before, after
+		}
+	}
+	for xy := range c.Blocks {
+		restore(xy)
+	}
+	for xy := range l.Blocks {
+		restore(xy)
+	}
+	ed := &restoreEdit{
+		blocks:     blocks,
+		bg:         bgEdit{before: c.BackgroundColor, after: l.BackgroundColor},
+		title:      textEdit{name: "title", field: courseTitle, before: c.Title, after: l.Title},
+		note:       textEdit{name: "note", field: courseNote, before: c.Note, after: l.Note},
+		opts:       &e.opts,
+		beforeOpts: e.opts,
+		afterOpts:  l.Settings,
+		art:        &e.art,
+		version:    &e.origin.version,
+		beforeV:    e.origin.version,
+		afterV:     v,
+	}
+	for i := range e.art {
+		ed.beforeArt[i], ed.afterArt[i] = e.art[i].Clone(), l.Art[i].Clone()
+	}
+	return ed
+}
+
+func (ed *restoreEdit) apply(c *course.Course) {
+	ed.blocks.apply(c)
+	ed.bg.apply(c)
+	ed.title.apply(c)
+	ed.note.apply(c)
+	*ed.opts = ed.afterOpts
+	for i := range ed.art {
+		ed.art[i] = ed.afterArt[i].Clone()
+	}
+	*ed.version = ed.afterV
+}
+
+func (ed *restoreEdit) revert(c *course.Course) {
+	ed.blocks.revert(c)
+	ed.bg.revert(c)
+	ed.title.revert(c)
+	ed.note.revert(c)
+	*ed.opts = ed.beforeOpts
+	for i := range ed.art {
+		ed.art[i] = ed.beforeArt[i].Clone()
+	}
+	*ed.version = ed.beforeV
+}
+
+// versionsWindow lists the versions of the level on the hub and compares
+// the selected one to the course.
+func (e *Editor) versionsWindow() {
+	if !e.showVersions {
+		return
+	}
+	if imgui.BeginV("Versions", &e.showVersions, imgui.WindowFlagsNone) {
+		if e.origin.levelID == "" {
+			imgui.Text("Only levels loaded from the hub have versions.")
+		} else {
+			e.pollVersion()
+			e.versionsList()
+		}
+	}
+	imgui.End()
+}
+
+func (e *Editor) versionsList() {
+	o, vs := e.origin, &e.versions
+	imgui.Text(fmt.Sprintf("Level #%s, opened at version %d of %d", o.levelID, o.version, o.latest))
+	imgui.Separator()
+	imgui.ColumnsV(2, "versions", true)
+	for v := o.latest; v >= 1; v-- {
+		label := fmt.Sprintf("Version %d", v)
+		if v == o.version {
+			label += " (opened)"
+		}
+		if imgui.SelectableV(label, v == vs.selected, 0, imgui.Vec2{}) {
+			vs.selected = v
+			e.fetchVersion(v)
+		}
+	}
+	imgui.NextColumn()
+
+	l, ok := e.selectedVersion()
+	switch {
+	case vs.selected == 0:
+		imgui.Text("Pick a version to compare it to the course.")
+	case vs.req != nil:
+		imgui.Text(fmt.Sprintf("Downloading version %d... %c", vs.reqFor, spinner()))
+	case vs.err != "":
+		imgui.Text(vs.err)
+	case ok:
+		if vs.preview == nil || vs.previewOf != vs.selected {
+			e.buildPreview(l)
+		}
+		if vs.preview != nil {
+			w, h := vs.preview.Size()
+			scale := fitScale(w, h)
+			imgui.Image(previewTexture, imgui.Vec2{X: float32(float64(w) * scale), Y: float32(float64(h) * scale)})
+		}
+
+		d, _ := e.versionDiff()
+		if d.Empty() {
+			imgui.Text("Same as the course.")
+		} else {
+			imgui.Text("Since this version the course has")
+			imgui.Text(fmt.Sprintf("%d cells placed, %d cleared and %d changed", len(d.Added), len(d.Removed), len(d.Changed)))
+			if len(d.Fields) > 0 {
+				imgui.Text("and a changed " + strings.Join(d.Fields, ", "))
+			}
+		}
+		imgui.Checkbox("Show changes", &vs.showDiff)
+		if e.readOnly {
+			imgui.Text("This level is open read-only, edit a copy to restore a version.")
+		} else if imgui.Button("Restore as working copy") {
+			e.restoreVersion()
+		}
+	}
+	imgui.Columns()
+}
+
+func (e *Editor) buildPreview(l *level.Level) {
+	vs := &e.versions
+	ov := level.OverviewOf(l.Course, minimapSize, blockColors)
+	tex, err := ebiten.NewImageFromImage(ov.Img, ebiten.FilterNearest)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if vs.preview != nil {
+		vs.preview.Dispose()
+	}
+	vs.preview, vs.previewOf = tex, vs.selected
+	e.mgr.Cache.SetTexture(previewTexture, tex)
+}
+
+var (
+	addedColor   color.Color = colornames.Limegreen
+	removedColor color.Color = colornames.Red
+	changedColor color.Color = colornames.Yellow
+)
+
+// drawVersionDiff outlines the cells that changed since the selected
+// version.
+func (e *Editor) drawVersionDiff(screen *ebiten.Image, cam ebiten.GeoM) {
+	if !e.showVersions || !e.versions.showDiff {
+		return
+	}
+	d, ok := e.versionDiff()
+	if !ok {
+		return
+	}
+	for _, c := range []struct {
+		cells []course.XY
+		clr   color.Color
+	}{
+		{d.Added, addedColor},
+		{d.Removed, removedColor},
+		{d.Changed, changedColor},
+	} {
+		for _, xy := range c.cells {
+			x, y := xytof(xy)
+			drawWorldRect(screen, cam, x+1, y+1, x+tileSize-1, y+tileSize-1, c.clr)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fourst4r/course"
+	"github.com/fourst4r/levedit/level"
+)
+
+func TestRestoreVersion(t *testing.T) {
+	e := &Editor{Course: &course.Course{Title: "new", Note: "edited"}, opts: level.DefaultSettings()}
+	e.art[level.Art1].Stamps = []level.Stamp{{X: 1, Y: 2, ID: 3}}
+	e.origin = origin{levelID: "42", version: 5, latest: 5}
+
+	old := &level.Level{Course: &course.Course{Title: "old"}, Settings: level.DefaultSettings()}
+	old.Settings.Time = 60
+	e.versions.selected = 2
+	e.versions.levels = map[int]*level.Level{2: old}
+	d, _ := e.versionDiff()
+	if d.Empty() {
+		t.Fatal("versionDiff() found nothing to restore")
+	}
+
+	e.restoreVersion()
+	if e.Course.Title != "old" || e.Course.Note != "" || e.opts.Time != 60 || len(e.art[level.Art1].Stamps) != 0 {
+		t.Errorf("restored %q %q, time %d, art %v", e.Course.Title, e.Course.Note, e.opts.Time, e.art[level.Art1])
+	}
+	if e.origin.version != 2 {
+		t.Errorf("restored version %d, want 2", e.origin.version)
+	}
+	if d, _ := e.versionDiff(); !d.Empty() {
+		t.Errorf("after restoring, versionDiff() = %+v, want none", d)
+	}
+	// the parsed version is shown again, so restoring must not share it
+	e.art[level.Art1].Stamps = append(e.art[level.Art1].Stamps, level.Stamp{})
+	if len(old.Art[level.Art1].Stamps) != 0 {
+		t.Error("editing the restored art changed the version")
+	}
+	e.art[level.Art1].Stamps = nil
+
+	e.undo()
+	if e.Course.Title != "new" || e.Course.Note != "edited" || e.opts.Time != level.DefaultSettings().Time {
+		t.Errorf("undone to %q %q, time %d", e.Course.Title, e.Course.Note, e.opts.Time)
+	}
+	if want := []level.Stamp{{X: 1, Y: 2, ID: 3}}; !reflect.DeepEqual(e.art[level.Art1].Stamps, want) {
+		t.Errorf("undone art = %v, want %v", e.art[level.Art1].Stamps, want)
+	}
+	if e.origin.version != 5 {
+		t.Errorf("undone version %d, want 5", e.origin.version)
+	}
+	if d, _ := e.versionDiff(); d.Empty() {
+		t.Error("versionDiff() wasn't updated after the undo")
+	}
+}
+
+func TestVersionDiffStroke(t *testing.T) {
+	e := &Editor{Course: courseOf(nil), opts: level.DefaultSettings()}
+	e.versions.selected = 1
+	e.versions.levels = map[int]*level.Level{1: {Course: courseOf(nil), Settings: level.DefaultSettings()}}
+	if d, _ := e.versionDiff(); !d.Empty() {
+		t.Fatalf("versionDiff() = %+v, want none", d)
+	}
+
+	// a stroke is compared once it ends
+	e.stroke = newBlocksEdit()
+	e.stroke.set(e.Course, cells(1, 1)[0], []int{1})
+	if d, _ := e.versionDiff(); !d.Empty() {
+		t.Errorf("versionDiff() during the stroke = %+v, want the cached diff", d)
+	}
+	e.endStroke()
+	if d, _ := e.versionDiff(); len(d.Added) != 1 {
+		t.Errorf("versionDiff() after the stroke = %+v, want one added cell", d)
+	}
+}